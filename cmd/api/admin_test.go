@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestAdminUsers(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	admin := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"List", http.MethodGet, "/v1/admin/users", admin, "", http.StatusOK, []byte("\"total_records\": 5")},
+		{"ListSearch", http.MethodGet, "/v1/admin/users?q=Vicky", admin, "", http.StatusOK, []byte("\"email\": \"vicky@gmail.com\"")},
+		{"ListFilter", http.MethodGet, "/v1/admin/users?activated=false", admin, "", http.StatusOK, []byte("\"email\": \"ayo@gmail.com\"")},
+		{"ListInvalidFilter", http.MethodGet, "/v1/admin/users?admin=maybe", admin, "", http.StatusUnprocessableEntity, []byte("must be a boolean value")},
+		{"ListInvalidSort", http.MethodGet, "/v1/admin/users?sort=password_hash", admin, "", http.StatusUnprocessableEntity, []byte("invalid sort value")},
+		{"ListNotAdmin", http.MethodGet, "/v1/admin/users", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "", http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"Show", http.MethodGet, "/v1/admin/users/1", admin, "", http.StatusOK, []byte("\"image_path\"")},
+		{"ShowNotFound", http.MethodGet, "/v1/admin/users/9", admin, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"Suspend", http.MethodPatch, "/v1/admin/users/3", admin, `{"suspended": true}`, http.StatusOK, []byte("\"suspended\": true")},
+		{"Promote", http.MethodPatch, "/v1/admin/users/4", admin, `{"admin": true}`, http.StatusOK, []byte("\"admin\": true")},
+		{"Activate", http.MethodPatch, "/v1/admin/users/2", admin, `{"activated": true}`, http.StatusOK, []byte("\"activated\": true")},
+		{"NoChanges", http.MethodPatch, "/v1/admin/users/3", admin, `{}`, http.StatusUnprocessableEntity, []byte("must change at least one of activated, suspended or admin")},
+		{"DemoteSelf", http.MethodPatch, "/v1/admin/users/1", admin, `{"admin": false}`, http.StatusUnprocessableEntity, []byte("must not be your own user account")},
+		{"ForcePasswordReset", http.MethodPost, "/v1/admin/users/3/password-reset", admin, "", http.StatusAccepted, []byte("password reset instructions")},
+		{"RevokeTokens", http.MethodDelete, "/v1/admin/users/3/tokens", admin, "", http.StatusOK, []byte("has been revoked")},
+		{"Delete", http.MethodDelete, "/v1/admin/users/3", admin, "", http.StatusOK, []byte("user account successfully deleted")},
+		{"DeleteSelf", http.MethodDelete, "/v1/admin/users/1", admin, "", http.StatusUnprocessableEntity, []byte("must not be your own user account")},
+		{"DeleteNotFound", http.MethodDelete, "/v1/admin/users/9", admin, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"Suspended", http.MethodGet, "/v1/movies/1", "Bearer SUS34GKUHNDUSJ3QRUT6IKWKRL", "", http.StatusForbidden, []byte("your user account has been suspended")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
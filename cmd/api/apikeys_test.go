@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAPIKeys(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Create", http.MethodPost, "/v1/users/api-keys", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", `{"name": "ingest", "permissions": ["movies:read"]}`, http.StatusCreated, []byte("\"key\": ")},
+		{"CreateNoName", http.MethodPost, "/v1/users/api-keys", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", `{"permissions": ["movies:read"]}`, http.StatusUnprocessableEntity, []byte("\"name\": \"must be provided\"")},
+		{"CreateNoPermissions", http.MethodPost, "/v1/users/api-keys", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", `{"name": "ingest"}`, http.StatusUnprocessableEntity, []byte("must contain at least 1 permission")},
+		{"CreateUnheldPermission", http.MethodPost, "/v1/users/api-keys", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", `{"name": "ingest", "permissions": ["movies:write"]}`, http.StatusUnprocessableEntity, []byte("must only contain permissions your user account holds")},
+		{"CreatePastExpiry", http.MethodPost, "/v1/users/api-keys", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", `{"name": "ingest", "permissions": ["movies:read"], "expiry": "` + past + `"}`, http.StatusUnprocessableEntity, []byte("must be in the future")},
+		{"CreateWithAPIKey", http.MethodPost, "/v1/users/api-keys", "ApiKey AKW34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", `{"name": "ingest", "permissions": ["movies:read"]}`, http.StatusForbidden, []byte("this resource cannot be accessed with an api key")},
+		{"List", http.MethodGet, "/v1/users/api-keys", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "", http.StatusOK, []byte("\"prefix\": \"AKW34GKU\"")},
+		{"Delete", http.MethodDelete, "/v1/users/api-keys/1", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "", http.StatusOK, []byte("api key successfully revoked")},
+		{"DeleteNotFound", http.MethodDelete, "/v1/users/api-keys/9", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "", http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"DeleteOtherUsers", http.MethodDelete, "/v1/users/api-keys/1", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "", http.StatusNotFound, []byte("the requested resource could not be found")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPIKeyAuthentication(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		header   string
+		key      string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"AuthorizationHeader", http.MethodGet, "/v1/movies/1", "Authorization", "ApiKey AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", "", http.StatusOK, []byte("Test Movie")},
+		{"XAPIKeyHeader", http.MethodGet, "/v1/movies/1", "X-API-Key", "AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", "", http.StatusOK, []byte("Test Movie")},
+		{"PermissionNotOnKey", http.MethodPatch, "/v1/movies/1", "X-API-Key", "AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", `{"title": "Key Movie"}`, http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"PermissionOnKey", http.MethodPatch, "/v1/movies/1", "X-API-Key", "AKW34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", `{"title": "Key Movie"}`, http.StatusOK, []byte("Key Movie")},
+		{"AccountEndpoint", http.MethodGet, "/v1/users/sessions", "X-API-Key", "AKW34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", "", http.StatusForbidden, []byte("this resource cannot be accessed with an api key")},
+		{"InvalidKey", http.MethodGet, "/v1/movies/1", "X-API-Key", "AKX34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", "", http.StatusUnauthorized, []byte("invalid, expired or revoked api key")},
+		{"MalformedKey", http.MethodGet, "/v1/movies/1", "Authorization", "ApiKey AKR34GKU", "", http.StatusUnauthorized, []byte("invalid, expired or revoked api key")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set(tt.header, tt.key)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAPIKeyCORSPreflight(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodOptions, ts.URL+"/v1/movies/1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Origin", "*")
+	req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+	req.Header.Set("Access-Control-Request-Headers", "X-API-Key")
+
+	code, header, _ := ts.do(t, req)
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+
+	if allowed := header.Get("Access-Control-Allow-Headers"); !strings.Contains(allowed, "X-API-Key") {
+		t.Errorf("want %q to allow X-API-Key", allowed)
+	}
+}
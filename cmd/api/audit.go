@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/tomasen/realip"
+)
+
+// audit records an action the user making the request took on the target
+// with the given type and id. before and after are the target on either side
+// of the change and may be nil, e.g. for creations and deletions.
+func (app *application) audit(r *http.Request, action, targetType string, targetID interface{}, before, after interface{}) {
+	var actorID *int64
+	if user := app.contextGetUser(r); !user.IsAnonymous() {
+		actorID = &user.ID
+	}
+	app.auditAs(r, actorID, action, targetType, targetID, before, after)
+}
+
+// auditAs is audit for requests made before the actor is authenticated, such
+// as signing in.
+func (app *application) auditAs(r *http.Request, actorID *int64, action, targetType string, targetID interface{}, before, after interface{}) {
+	event := &data.AuditEvent{
+		ActorID:    actorID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   fmt.Sprint(targetID),
+		IP:         realip.FromRequest(r),
+		UserAgent:  r.UserAgent(),
+	}
+
+	var err error
+	if before != nil {
+		event.Before, err = json.Marshal(before)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+	if after != nil {
+		event.After, err = json.Marshal(after)
+		if err != nil {
+			app.logError(r, err)
+		}
+	}
+
+	// The action has already taken place, so a failure to record it is
+	// logged rather than turned into an error response.
+	err = app.models.Audit.Insert(event)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	if app.config.audit.log {
+		properties := map[string]string{
+			"action":      event.Action,
+			"target_type": event.TargetType,
+			"target_id":   event.TargetID,
+			"ip":          event.IP,
+			"user_agent":  event.UserAgent,
+		}
+		if actorID != nil {
+			properties["actor_id"] = strconv.FormatInt(*actorID, 10)
+		}
+		if event.Before != nil {
+			properties["before"] = string(event.Before)
+		}
+		if event.After != nil {
+			properties["after"] = string(event.After)
+		}
+		app.logger.PrintInfo("audit event", properties)
+	}
+}
+
+func (app *application) listAuditEventsHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		data.AuditFilter
+		data.Filters
+	}
+
+	v := validator.New()
+
+	qs := r.URL.Query()
+
+	if qs.Has("actor_id") {
+		actorID := int64(app.readInt(qs, "actor_id", 0, v))
+		v.Check(actorID > 0, "actor_id", "must be a positive integer")
+		input.AuditFilter.ActorID = &actorID
+	}
+	input.AuditFilter.Action = app.readString(qs, "action", "")
+	input.AuditFilter.TargetType = app.readString(qs, "target_type", "")
+	input.AuditFilter.TargetID = app.readString(qs, "target_id", "")
+	input.AuditFilter.Since = app.readTime(qs, "since", v)
+	input.AuditFilter.Until = app.readTime(qs, "until", v)
+	input.Filters.Page = app.readInt(qs, "page", 1, v)
+	input.Filters.PageSize = app.readInt(qs, "page_size", 20, v)
+	input.Filters.Sort = app.readString(qs, "sort", "-id")
+	input.Filters.SortSafelist = []string{"id", "created_at", "-id", "-created_at"}
+
+	if input.AuditFilter.TargetID != "" {
+		v.Check(input.AuditFilter.TargetType != "", "target_type", "must be provided along with target_id")
+	}
+	if input.AuditFilter.Since != nil && input.AuditFilter.Until != nil {
+		v.Check(input.AuditFilter.Since.Before(*input.AuditFilter.Until), "until", "must be after since")
+	}
+
+	if data.ValidateFilters(v, input.Filters); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	events, metadata, err := app.models.Audit.GetAll(input.AuditFilter, input.Filters)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"events": events, "metadata": metadata}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAuditLog(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	admin := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"
+
+	actions := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		payload  string
+		wantCode int
+	}{
+		{"CreateMovie", http.MethodPost, "/v1/movies", admin, `{"title": "Audited", "year": 2020, "runtime": "100 mins", "genres": ["drama"]}`, http.StatusCreated},
+		{"Login", http.MethodPost, "/v1/tokens/authentication", "", `{"email": "olalekanawe99@gmail.com", "password": "1234567890", "device_name": "laptop"}`, http.StatusCreated},
+		{"Suspend", http.MethodPatch, "/v1/admin/users/3", admin, `{"suspended": true}`, http.StatusOK},
+	}
+
+	for _, action := range actions {
+		req, err := http.NewRequest(action.method, ts.URL+action.urlPath, strings.NewReader(action.payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if action.token != "" {
+			req.Header.Set("Authorization", action.token)
+		}
+
+		code, _, _ := ts.do(t, req)
+		if code != action.wantCode {
+			t.Fatalf("%s: want %d; got %d", action.name, action.wantCode, code)
+		}
+	}
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		token    string
+		wantCode int
+		wantBody [][]byte
+	}{
+		{"All", "/v1/audit", admin, http.StatusOK, [][]byte{[]byte("\"total_records\": 3")}},
+		{"Action", "/v1/audit?action=movie.create", admin, http.StatusOK, [][]byte{[]byte("\"actor_id\": 1"), []byte("\"title\": \"Audited\"")}},
+		{"Login", "/v1/audit?action=user.login", admin, http.StatusOK, [][]byte{[]byte("\"actor_id\": 1"), []byte("\"method\": \"password\""), []byte("\"device_name\": \"laptop\"")}},
+		{"Target", "/v1/audit?target_type=user&target_id=3", admin, http.StatusOK, [][]byte{[]byte("\"action\": \"admin.user.update\""), []byte("\"suspended\": false"), []byte("\"suspended\": true")}},
+		{"Actor", "/v1/audit?actor_id=2", admin, http.StatusOK, [][]byte{[]byte("\"events\": []")}},
+		{"Until", "/v1/audit?until=" + past, admin, http.StatusOK, [][]byte{[]byte("\"events\": []")}},
+		{"TargetIDOnly", "/v1/audit?target_id=3", admin, http.StatusUnprocessableEntity, [][]byte{[]byte("must be provided along with target_id")}},
+		{"InvalidSince", "/v1/audit?since=yesterday", admin, http.StatusUnprocessableEntity, [][]byte{[]byte("must be an RFC 3339 timestamp")}},
+		{"InvalidActor", "/v1/audit?actor_id=abc", admin, http.StatusUnprocessableEntity, [][]byte{[]byte("must be an integer value")}},
+		{"InvalidSort", "/v1/audit?sort=action", admin, http.StatusUnprocessableEntity, [][]byte{[]byte("invalid sort value")}},
+		{"NotAdmin", "/v1/audit", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", http.StatusForbidden, [][]byte{[]byte("your user account is not permitted to access this resource")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			for _, want := range tt.wantBody {
+				if !bytes.Contains(body, want) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+		})
+	}
+}
@@ -9,7 +9,13 @@ import (
 
 type contextKey string
 
-const userContextKey = contextKey("user")
+const (
+	userContextKey   = contextKey("user")
+	tokenContextKey  = contextKey("token")
+	apiKeyContextKey = contextKey("apiKey")
+	oauthContextKey  = contextKey("oauth")
+	jwtContextKey    = contextKey("jwt")
+)
 
 func (app *application) contextSetUser(r *http.Request, user *data.User) *http.Request {
 	ctx := context.WithValue(r.Context(), userContextKey, user)
@@ -23,3 +29,59 @@ func (app *application) contextGetUser(r *http.Request) *data.User {
 	}
 	return user
 }
+
+// contextSetToken stores the hash of the authentication token the request was
+// made with. For signed tokens this is the hash of the opaque token they were
+// issued for, which identifies the session.
+func (app *application) contextSetToken(r *http.Request, tokenHash []byte) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenContextKey, tokenHash)
+	return r.WithContext(ctx)
+}
+
+func (app *application) contextGetToken(r *http.Request) []byte {
+	tokenHash, ok := r.Context().Value(tokenContextKey).([]byte)
+	if !ok {
+		panic("missing token value in request context")
+	}
+	return tokenHash
+}
+
+// contextSetAPIKey stores the API key the request was made with.
+func (app *application) contextSetAPIKey(r *http.Request, key *data.APIKey) *http.Request {
+	ctx := context.WithValue(r.Context(), apiKeyContextKey, key)
+	return r.WithContext(ctx)
+}
+
+// contextGetAPIKey returns the API key the request was made with, or nil if
+// it was not made with one.
+func (app *application) contextGetAPIKey(r *http.Request) *data.APIKey {
+	key, _ := r.Context().Value(apiKeyContextKey).(*data.APIKey)
+	return key
+}
+
+// contextSetOAuthToken stores the OAuth access token the request was made with.
+func (app *application) contextSetOAuthToken(r *http.Request, token *data.Token) *http.Request {
+	ctx := context.WithValue(r.Context(), oauthContextKey, token)
+	return r.WithContext(ctx)
+}
+
+// contextGetOAuthToken returns the OAuth access token the request was made
+// with, or nil if it was not made with one.
+func (app *application) contextGetOAuthToken(r *http.Request) *data.Token {
+	token, _ := r.Context().Value(oauthContextKey).(*data.Token)
+	return token
+}
+
+// contextSetJWTClaims stores the claims of the signed token the request was
+// made with.
+func (app *application) contextSetJWTClaims(r *http.Request, claims *accessClaims) *http.Request {
+	ctx := context.WithValue(r.Context(), jwtContextKey, claims)
+	return r.WithContext(ctx)
+}
+
+// contextGetJWTClaims returns the claims of the signed token the request was
+// made with, or nil if it was not made with one.
+func (app *application) contextGetJWTClaims(r *http.Request) *accessClaims {
+	claims, _ := r.Context().Value(jwtContextKey).(*accessClaims)
+	return claims
+}
@@ -0,0 +1,219 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+)
+
+// emailRevertTTL is how long the owner of the old email address has to undo
+// an email change.
+const emailRevertTTL = 7 * 24 * time.Hour
+
+// sendEmailChangeTokens emails a confirmation token to the pending email
+// address of the user and a revert token to their current one. The revert
+// token restores the current address, however many changes follow it.
+func (app *application) sendEmailChangeTokens(r *http.Request, user *data.User) error {
+	err := app.models.Tokens.DeleteAllForUser(data.ScopeEmailChange, user.ID, nil)
+	if err != nil {
+		return err
+	}
+
+	changeToken, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeEmailChange, r)
+	if err != nil {
+		return err
+	}
+
+	revertToken, err := app.models.Tokens.NewEmailRevert(user.ID, user.Email, emailRevertTTL, r)
+	if err != nil {
+		return err
+	}
+
+	oldEmail, newEmail := user.Email, user.PendingEmail
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"emailChangeToken": changeToken.Plaintext,
+			"newEmail":         newEmail,
+		}
+
+		err := app.mailer.Send(newEmail, "email_change_confirm.html", data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"emailRevertToken": revertToken.Plaintext,
+			"newEmail":         newEmail,
+		}
+
+		err := app.mailer.Send(oldEmail, "email_change_notice.html", data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	return nil
+}
+
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeEmailChange, input.TokenPlaintext)
+	if err == nil && user.PendingEmail == "" {
+		err = data.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
+			v.AddError("token", "invalid or expired email change token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	before := *user
+
+	user.Email = user.PendingEmail
+	user.PendingEmail = ""
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeEmailChange, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.auditAs(r, &user.ID, "user.email_change", "user", user.ID, before, user)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revertEmailChangeHandler lets the owner of the old email address cancel a
+// pending change or undo a confirmed one, restoring the address the revert
+// token was sent to and cancelling any change requested since. As the account
+// may have been taken over, every session and API key is revoked as well.
+func (app *application) revertEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
+	user, email, err := app.models.Users.GetForEmailRevertToken(input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
+			v.AddError("token", "invalid or expired email revert token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	before := *user
+
+	user.Email = email
+	user.PendingEmail = ""
+
+	err = app.models.Users.Update(user)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrDuplicateEmail):
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrEditConflict):
+			app.editConflictResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	for _, scope := range []string{data.ScopeEmailChange, data.ScopeEmailRevert} {
+		err = app.models.Tokens.DeleteAllForUser(scope, user.ID, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+	}
+
+	err = app.revokeAllCredentials(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logSecurityEvent(r, "email_change_reverted", map[string]string{
+		"user_id": strconv.FormatInt(user.ID, 10),
+	})
+	app.auditAs(r, &user.ID, "user.email_revert", "user", user.ID, before, user)
+
+	env := envelope{"message": "the email change has been reverted and every session and api key revoked, please sign in again and change your password"}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
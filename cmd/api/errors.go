@@ -2,7 +2,11 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
 func (app *application) logError(r *http.Request, err error) {
@@ -12,9 +16,77 @@ func (app *application) logError(r *http.Request, err error) {
 	})
 }
 
-func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, message interface{}) {
+// errorCode is a stable, machine-readable identifier for an error response.
+// Clients should match on it rather than on the human-readable message.
+type errorCode string
+
+const (
+	codeBadRequest             errorCode = "bad_request"
+	codeServerError            errorCode = "server_error"
+	codeNotFound               errorCode = "not_found"
+	codeMethodNotAllowed       errorCode = "method_not_allowed"
+	codeFailedValidation       errorCode = "failed_validation"
+	codeEditConflict           errorCode = "edit_conflict"
+	codeRateLimitExceeded      errorCode = "rate_limit_exceeded"
+	codeInvalidCredentials     errorCode = "invalid_credentials"
+	codeInvalidToken           errorCode = "invalid_token"
+	codeAuthenticationRequired errorCode = "authentication_required"
+	codeDuplicatePermission    errorCode = "duplicate_permission"
+	codeInactiveAccount        errorCode = "inactive_account"
+	codeSuspendedAccount       errorCode = "suspended_account"
+	codeNotPermitted           errorCode = "not_permitted"
+	codeInvalidPassword        errorCode = "invalid_password"
+	codeDuplicateProfile       errorCode = "duplicate_profile"
+	codeDuplicateReview        errorCode = "duplicate_review"
+	codeDuplicateCredit        errorCode = "duplicate_credit"
+	codeDuplicateWatchlist     errorCode = "duplicate_watchlist_entry"
+	codeDuplicateListEntry     errorCode = "duplicate_list_entry"
+	codePreconditionFailed     errorCode = "precondition_failed"
+	codePreconditionRequired   errorCode = "precondition_required"
+	codeInvalidMFACode         errorCode = "invalid_mfa_code"
+	codeMFAEnabled             errorCode = "mfa_enabled"
+	codeMFARequired            errorCode = "mfa_required"
+	codeTooManyAttempts        errorCode = "too_many_attempts"
+	codeInvalidAPIKey          errorCode = "invalid_api_key"
+	codeAPIKeyNotAllowed       errorCode = "api_key_not_allowed"
+	codeOAuthTokenNotAllowed   errorCode = "oauth_token_not_allowed"
+)
+
+const problemContentType = "application/problem+json"
+
+// wantsProblem reports whether the error should be sent as an RFC 7807
+// problem document, either because the client asked for one or because the
+// server has been configured to always send them.
+func (app *application) wantsProblem(r *http.Request) bool {
+	return app.config.problemJSON || strings.Contains(r.Header.Get("Accept"), problemContentType)
+}
+
+func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, status int, code errorCode, message interface{}) {
 	env := envelope{"error": message}
-	err := app.writeJSON(w, status, env, nil)
+	var headers http.Header
+
+	if app.wantsProblem(r) {
+		env = envelope{
+			"type":     "urn:greenlight:problem:" + string(code),
+			"title":    http.StatusText(status),
+			"status":   status,
+			"instance": r.URL.Path,
+			"code":     code,
+		}
+		switch message := message.(type) {
+		case map[string]string:
+			env["detail"] = "one or more fields failed validation"
+			env["errors"] = message
+		default:
+			env["detail"] = message
+		}
+		headers = make(http.Header)
+		headers.Set("Content-Type", problemContentType)
+	}
+
+	w.Header().Add("Vary", "Accept")
+
+	err := app.writeJSON(w, status, env, headers)
 	if err != nil {
 		app.logError(r, err)
 		w.WriteHeader(500)
@@ -22,76 +94,165 @@ func (app *application) errorResponse(w http.ResponseWriter, r *http.Request, st
 }
 
 func (app *application) badRequestResponse(w http.ResponseWriter, r *http.Request, err error) {
-	app.errorResponse(w, r, http.StatusBadRequest, err.Error())
+	app.errorResponse(w, r, http.StatusBadRequest, codeBadRequest, err.Error())
 }
 
 func (app *application) serverErrorResponse(w http.ResponseWriter, r *http.Request, err error) {
 	app.logError(r, err)
 	message := "the server encountered a problem and could not process your request"
-	app.errorResponse(w, r, http.StatusInternalServerError, message)
+	app.errorResponse(w, r, http.StatusInternalServerError, codeServerError, message)
 }
 
 func (app *application) notFoundResponse(w http.ResponseWriter, r *http.Request) {
 	message := "the requested resource could not be found"
-	app.errorResponse(w, r, http.StatusNotFound, message)
+	app.errorResponse(w, r, http.StatusNotFound, codeNotFound, message)
 }
 
 func (app *application) methodNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
 	message := fmt.Sprintf("the %s method is not supported for this resource", r.Method)
-	app.errorResponse(w, r, http.StatusMethodNotAllowed, message)
+	app.errorResponse(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, message)
 }
 
 func (app *application) failedValidationResponse(w http.ResponseWriter, r *http.Request, errors map[string]string) {
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, errors)
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeFailedValidation, errors)
 }
 
 func (app *application) editConflictResponse(w http.ResponseWriter, r *http.Request) {
 	message := "unable to update the record due to an edit conflict, please try again"
-	app.errorResponse(w, r, http.StatusConflict, message)
+	app.errorResponse(w, r, http.StatusConflict, codeEditConflict, message)
 }
 
 func (app *application) rateLimitExceededResponse(w http.ResponseWriter, r *http.Request) {
 	message := "rate limit exceeded"
-	app.errorResponse(w, r, http.StatusTooManyRequests, message)
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeRateLimitExceeded, message)
 }
 
 func (app *application) invalidCredentialsResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid authentication credentials"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidCredentials, message)
 }
 
 func (app *application) invalidAuthenticationTokenResponse(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("WWW-Authenticate", "Bearer")
 	message := "invalid or missing authentication token"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidToken, message)
 }
 
 func (app *application) authenticationRequiredResponse(w http.ResponseWriter, r *http.Request) {
 	message := "you must be authenticated to access this resource"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeAuthenticationRequired, message)
 }
 
 func (app *application) duplicatePermisionResponse(w http.ResponseWriter, r *http.Request) {
 	message := "This user already has this permission"
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeDuplicatePermission, message)
 }
 
 func (app *application) inactiveAccountResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account must be activated to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeInactiveAccount, message)
+}
+
+func (app *application) suspendedAccountResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account has been suspended"
+	app.errorResponse(w, r, http.StatusForbidden, codeSuspendedAccount, message)
 }
 
 func (app *application) notPermittedResponse(w http.ResponseWriter, r *http.Request) {
 	message := "your user account is not permitted to access this resource"
-	app.errorResponse(w, r, http.StatusForbidden, message)
+	app.errorResponse(w, r, http.StatusForbidden, codeNotPermitted, message)
 }
 
 func (app *application) invalidPasswordResponse(w http.ResponseWriter, r *http.Request) {
 	message := "invalid password"
-	app.errorResponse(w, r, http.StatusUnauthorized, message)
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidPassword, message)
 }
 
 func (app *application) duplicateProfiledResponse(w http.ResponseWriter, r *http.Request) {
 	message := "cannot have more than one profile picture"
-	app.errorResponse(w, r, http.StatusUnprocessableEntity, message)
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeDuplicateProfile, message)
+}
+
+func (app *application) duplicateReviewResponse(w http.ResponseWriter, r *http.Request) {
+	message := "you have already reviewed this movie"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeDuplicateReview, message)
+}
+
+func (app *application) duplicateCreditResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this person has already been credited with this role on the movie"
+	app.errorResponse(w, r, http.StatusConflict, codeDuplicateCredit, message)
+}
+
+func (app *application) duplicateWatchlistEntryResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this movie is already on your watchlist"
+	app.errorResponse(w, r, http.StatusConflict, codeDuplicateWatchlist, message)
+}
+
+func (app *application) duplicateListEntryResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this movie is already on the list"
+	app.errorResponse(w, r, http.StatusConflict, codeDuplicateListEntry, message)
+}
+
+func (app *application) preconditionFailedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "the resource has been modified since it was last retrieved, please fetch it again"
+	app.errorResponse(w, r, http.StatusPreconditionFailed, codePreconditionFailed, message)
+}
+
+func (app *application) preconditionRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this request must be made conditional with an If-Match header"
+	app.errorResponse(w, r, http.StatusPreconditionRequired, codePreconditionRequired, message)
+}
+
+func (app *application) invalidMFACodeResponse(w http.ResponseWriter, r *http.Request) {
+	message := "invalid two-factor authentication code"
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidMFACode, message)
+}
+
+func (app *application) mfaEnabledResponse(w http.ResponseWriter, r *http.Request) {
+	message := "two-factor authentication is already enabled for your user account"
+	app.errorResponse(w, r, http.StatusUnprocessableEntity, codeMFAEnabled, message)
+}
+
+func (app *application) mfaRequiredResponse(w http.ResponseWriter, r *http.Request) {
+	message := "your user account must have two-factor authentication enabled to access this resource"
+	app.errorResponse(w, r, http.StatusForbidden, codeMFARequired, message)
+}
+
+func (app *application) tooManyAttemptsResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	message := "too many failed attempts, please try again later"
+	app.errorResponse(w, r, http.StatusTooManyRequests, codeTooManyAttempts, message)
+}
+
+func (app *application) invalidAPIKeyResponse(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", "ApiKey")
+	message := "invalid, expired or revoked api key"
+	app.errorResponse(w, r, http.StatusUnauthorized, codeInvalidAPIKey, message)
+}
+
+func (app *application) apiKeyNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this resource cannot be accessed with an api key"
+	app.errorResponse(w, r, http.StatusForbidden, codeAPIKeyNotAllowed, message)
+}
+
+func (app *application) oauthTokenNotAllowedResponse(w http.ResponseWriter, r *http.Request) {
+	message := "this resource cannot be accessed with an oauth access token"
+	app.errorResponse(w, r, http.StatusForbidden, codeOAuthTokenNotAllowed, message)
+}
+
+// oauthErrorResponse sends an error from the OAuth endpoints in the shape RFC
+// 6749 prescribes rather than the usual error envelope, as OAuth client
+// libraries expect.
+func (app *application) oauthErrorResponse(w http.ResponseWriter, r *http.Request, status int, code, description string) {
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+	if status == http.StatusUnauthorized {
+		headers.Set("WWW-Authenticate", `Basic realm="oauth"`)
+	}
+
+	err := app.writeJSON(w, status, envelope{"error": code, "error_description": description}, headers)
+	if err != nil {
+		app.logError(r, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
 }
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestProblemResponses(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		method    string
+		urlPath   string
+		body      string
+		token     string
+		wantCode  int
+		wantCodes []string
+	}{
+		{"AuthenticationRequired", http.MethodGet, "/v1/movies/1", "", "", http.StatusUnauthorized, []string{`"code": "authentication_required"`, `"type": "urn:greenlight:problem:authentication_required"`, `"title": "Unauthorized"`, `"instance": "/v1/movies/1"`}},
+		{"InvalidToken", http.MethodGet, "/v1/movies/1", "", "Bearer foo", http.StatusUnauthorized, []string{`"code": "invalid_token"`, `"status": 401`}},
+		{"NotFound", http.MethodGet, "/v1/foo", "", "", http.StatusNotFound, []string{`"code": "not_found"`, `"detail": "the requested resource could not be found"`}},
+		{"BadRequest", http.MethodPost, "/v1/tokens/authentication", "{", "", http.StatusBadRequest, []string{`"code": "bad_request"`}},
+		{"FailedValidation", http.MethodPost, "/v1/tokens/authentication", `{"email": "foo"}`, "", http.StatusUnprocessableEntity, []string{`"code": "failed_validation"`, `"errors": {`, `"email": "must be a valid email address"`}},
+		{"MethodNotAllowed", http.MethodPut, "/v1/healthcheck", "", "", http.StatusMethodNotAllowed, []string{`"code": "method_not_allowed"`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, strings.NewReader(tt.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Accept", "application/problem+json")
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/problem+json" {
+				t.Errorf("want %q; got %q", "application/problem+json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			for _, want := range tt.wantCodes {
+				if !bytes.Contains(body, []byte(want)) {
+					t.Errorf("want body to contain %q", want)
+				}
+			}
+		})
+	}
+
+	t.Run("LegacyFormat", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/movies/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		code, header, body := ts.do(t, req)
+		if contentType := header.Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("want %q; got %q", "application/json", contentType)
+		}
+
+		if code != http.StatusUnauthorized {
+			t.Errorf("want %d; got %d", http.StatusUnauthorized, code)
+		}
+
+		if want := []byte(`"error": "you must be authenticated to access this resource"`); !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	})
+
+	t.Run("GlobalFlag", func(t *testing.T) {
+		app.config.problemJSON = true
+		defer func() { app.config.problemJSON = false }()
+
+		req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/movies/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, header, body := ts.do(t, req)
+		if contentType := header.Get("Content-Type"); contentType != "application/problem+json" {
+			t.Errorf("want %q; got %q", "application/problem+json", contentType)
+		}
+
+		if want := []byte(`"code": "authentication_required"`); !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+// movieETag returns a strong entity tag for a movie. The version is bumped on
+// every change to the movie itself, so id and version identify its state.
+// Reviews change the rating aggregate without bumping the version, so the
+// rating count and average are part of the tag as well. The watchlist and
+// watched flags of the request user are part of the response too, so they
+// are appended when set.
+func movieETag(movie *data.Movie) string {
+	etag := fmt.Sprintf("%d-%d-%d-%s", movie.ID, movie.Version, movie.RatingCount, strconv.FormatFloat(movie.AverageRating, 'f', -1, 64))
+
+	flags := ""
+	if movie.InWatchlist != nil && *movie.InWatchlist {
+		flags += "l"
+	}
+	if movie.Watched != nil && *movie.Watched {
+		flags += "w"
+	}
+	if flags != "" {
+		etag += "-" + flags
+	}
+	return `"` + etag + `"`
+}
+
+// weakETag returns a weak entity tag derived from the JSON encoding of env,
+// for responses such as listings that have no version of their own.
+func weakETag(env envelope) (string, error) {
+	js, err := json.Marshal(env)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(js)
+	return `W/"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+// etagMatches reports whether etag is listed in an If-Match or If-None-Match
+// header value. Weak comparison ignores the W/ prefix on both sides, while
+// strong comparison never matches a weak tag.
+func etagMatches(header, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+			continue
+		}
+		if !strings.HasPrefix(candidate, "W/") && candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// notModified writes a 304 response and returns true when the request's
+// If-None-Match header matches etag.
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, etag string) bool {
+	header := r.Header.Get("If-None-Match")
+	if header == "" || !etagMatches(header, etag, true) {
+		return false
+	}
+	w.Header().Set("ETag", etag)
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
+
+// checkIfMatch enforces the If-Match precondition for a request that changes
+// a movie. It writes the error response and returns false when the request
+// must not go ahead.
+func (app *application) checkIfMatch(w http.ResponseWriter, r *http.Request, movie *data.Movie) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		if app.config.conditional.requireIfMatch {
+			app.preconditionRequiredResponse(w, r)
+			return false
+		}
+		return true
+	}
+
+	if !etagMatches(header, movieETag(movie), false) {
+		app.preconditionFailedResponse(w, r)
+		return false
+	}
+	return true
+}
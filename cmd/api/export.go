@@ -0,0 +1,286 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+)
+
+// exportPath returns where the archive downloaded with the export token with
+// the given hash is written. Only the hash is used, so that the plaintext of
+// the token never touches the disk.
+func (app *application) exportPath(tokenHash []byte) string {
+	return filepath.Join(app.config.export.dir, hex.EncodeToString(tokenHash)+".zip")
+}
+
+func (app *application) createExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	// Only the latest export can be downloaded, the archives of earlier ones
+	// are removed by sweepExports.
+	err := app.models.Tokens.DeleteAllForUser(data.ScopeExport, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, app.config.export.ttl, data.ScopeExport, r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		path := app.exportPath(token.Hash)
+
+		err := app.writeExport(user, path)
+		if err != nil {
+			app.logger.PrintError(err, map[string]string{"user_id": strconv.FormatInt(user.ID, 10)})
+
+			err = app.models.Tokens.DeleteAllForUser(data.ScopeExport, user.ID, nil)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+			return
+		}
+
+		data := map[string]interface{}{
+			"exportToken": token.Plaintext,
+			"expiry":      token.Expiry.Format(time.RFC1123),
+		}
+
+		err = app.mailer.Send(user.Email, "user_export.html", data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+
+	app.audit(r, "user.export", "user", user.ID, nil, nil)
+
+	env := envelope{"message": "your data is being exported and an email will be sent to you containing a link to download it"}
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// writeExport writes a zip archive of the personal data of the user to path.
+// The archive is assembled next to path and only renamed into place once it
+// is complete, so that a download never sees a partial archive.
+func (app *application) writeExport(user *data.User, path string) (err error) {
+	tmp := path + ".tmp"
+
+	file, err := os.OpenFile(tmp, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			file.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	zw := zip.NewWriter(file)
+
+	err = addExportJSON(zw, "account.json", user)
+	if err != nil {
+		return err
+	}
+
+	profile, err := app.models.UsersProfile.Get(user.ID)
+	switch {
+	case err == nil:
+		err = addExportJSON(zw, "profile.json", profile)
+		if err != nil {
+			return err
+		}
+
+		err = addExportFile(zw, "profile_picture"+filepath.Ext(profile.ImagePath), filepath.Join("images", profile.ImagePath))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	case !errors.Is(err, data.ErrRecordNotFound):
+		return err
+	}
+
+	movies, err := app.models.Movies.GetAllForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	err = addExportJSON(zw, "movies.json", movies)
+	if err != nil {
+		return err
+	}
+
+	sessions, err := app.models.Tokens.GetSessionsForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	err = addExportJSON(zw, "sessions.json", sessions)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	roles, err := app.models.Permissions.GetRolesForUser(user.ID)
+	if err != nil {
+		return err
+	}
+	if permissions == nil {
+		permissions = data.Permissions{}
+	}
+	err = addExportJSON(zw, "permissions.json", envelope{"roles": roles, "permissions": permissions})
+	if err != nil {
+		return err
+	}
+
+	err = zw.Close()
+	if err != nil {
+		return err
+	}
+	err = file.Close()
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, path)
+}
+
+func addExportJSON(zw *zip.Writer, name string, v interface{}) error {
+	js, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		return err
+	}
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(append(js, '\n'))
+	return err
+}
+
+func addExportFile(zw *zip.Writer, name, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(w, file)
+	return err
+}
+
+func (app *application) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	tokenPlaintext := app.readString(r.URL.Query(), "token", "")
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, tokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// The link only works for the account it was sent to, so that a leaked
+	// email is not enough to download the archive.
+	owner, err := app.models.Users.GetForToken(data.ScopeExport, tokenPlaintext)
+	if err == nil && owner.ID != app.contextGetUser(r).ID {
+		err = data.ErrRecordNotFound
+	}
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("token", "invalid or expired download token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	hash := sha256.Sum256([]byte(tokenPlaintext))
+	path := app.exportPath(hash[:])
+
+	file, err := os.Open(path)
+	if err != nil {
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+	defer file.Close()
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="greenlight-export.zip"`)
+	w.Header().Set("Cache-Control", "no-store")
+
+	_, err = io.Copy(w, file)
+	if err != nil {
+		// The response has already started, so the archive is kept for
+		// another attempt and the error only logged.
+		app.logError(r, err)
+		return
+	}
+
+	// A downloaded archive is expired straight away.
+	err = os.Remove(path)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeExport, owner.ID, nil)
+	if err != nil {
+		app.logError(r, err)
+	}
+
+	app.audit(r, "user.export_download", "user", owner.ID, nil, nil)
+}
+
+// sweepExports periodically removes the archives of exports that can no
+// longer be downloaded, as well as those of failed or superseded ones.
+func (app *application) sweepExports() {
+	app.periodically(time.Hour, func() {
+		entries, err := os.ReadDir(app.config.export.dir)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+
+		cutoff := time.Now().Add(-app.config.export.ttl)
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil || entry.IsDir() || info.ModTime().After(cutoff) {
+				continue
+			}
+
+			err = os.Remove(filepath.Join(app.config.export.dir, entry.Name()))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				app.logger.PrintError(err, nil)
+			}
+		}
+	})
+}
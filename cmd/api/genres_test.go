@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestGenres(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	admin := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"
+	reader := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"List", http.MethodGet, "/v1/genres", reader, "", http.StatusOK, []byte("\"movie_count\": 1")},
+		{"ListUnauthenticated", http.MethodGet, "/v1/genres", "", "", http.StatusUnauthorized, []byte("you must be authenticated to access this resource")},
+		{"Create", http.MethodPost, "/v1/admin/genres", admin, `{"name": "Film Noir", "aliases": ["Noir"]}`, http.StatusCreated, []byte("\"slug\": \"film-noir\"")},
+		{"CreateDuplicate", http.MethodPost, "/v1/admin/genres", admin, `{"name": "Sci-Fi"}`, http.StatusUnprocessableEntity, []byte("a genre with this slug or alias already exists")},
+		{"CreateInvalidSlug", http.MethodPost, "/v1/admin/genres", admin, `{"name": "Noir", "slug": "Film Noir"}`, http.StatusUnprocessableEntity, []byte("must only contain lowercase letters, digits and single hyphens")},
+		{"CreateNotAdmin", http.MethodPost, "/v1/admin/genres", reader, `{"name": "Film Noir"}`, http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"Rename", http.MethodPatch, "/v1/admin/genres/6", admin, `{"name": "Sci-Fi", "slug": "sci-fi-movies"}`, http.StatusOK, []byte("\"science-fiction\"")},
+		{"RenameNotFound", http.MethodPatch, "/v1/admin/genres/99", admin, `{"name": "Sci-Fi"}`, http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"Merge", http.MethodPost, "/v1/admin/genres/8/merge", admin, `{"into": 6}`, http.StatusOK, []byte("\"sf\"")},
+		{"MergeIntoItself", http.MethodPost, "/v1/admin/genres/8/merge", admin, `{"into": 8}`, http.StatusUnprocessableEntity, []byte("must not be the genre being merged")},
+		{"MergeIntoUnknown", http.MethodPost, "/v1/admin/genres/8/merge", admin, `{"into": 99}`, http.StatusUnprocessableEntity, []byte("must be the id of an existing genre")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, strings.NewReader(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -1,6 +1,9 @@
 package main
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,7 +12,9 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/IfedayoAwe/greenlight/internal/data"
 	"github.com/IfedayoAwe/greenlight/internal/validator"
 	"github.com/julienschmidt/httprouter"
 )
@@ -23,6 +28,33 @@ func (app *application) readIDParam(r *http.Request) (int64, error) {
 	return id, nil
 }
 
+func (app *application) readVersionParam(r *http.Request) (int32, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	version, err := strconv.ParseInt(params.ByName("version"), 10, 32)
+	if err != nil || version < 1 {
+		return 0, errors.New("invalid version parameter")
+	}
+	return int32(version), nil
+}
+
+func (app *application) readCreditIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("credit_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid credit_id parameter")
+	}
+	return id, nil
+}
+
+func (app *application) readMovieIDParam(r *http.Request) (int64, error) {
+	params := httprouter.ParamsFromContext(r.Context())
+	id, err := strconv.ParseInt(params.ByName("movie_id"), 10, 64)
+	if err != nil || id < 1 {
+		return 0, errors.New("invalid movie_id parameter")
+	}
+	return id, nil
+}
+
 type envelope map[string]interface{}
 
 func (app *application) writeJSON(w http.ResponseWriter, status int, data envelope, headers http.Header) error {
@@ -36,7 +68,9 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data envelo
 		w.Header()[key] = value
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 	w.Write(js)
 
@@ -119,6 +153,110 @@ func (app *application) readInt(qs url.Values, key string, defaultValue int, v *
 	return i
 }
 
+// readBool returns nil when key is not in the query string, so that it can be
+// told apart from false.
+func (app *application) readBool(qs url.Values, key string, v *validator.Validator) *bool {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		v.AddError(key, "must be a boolean value")
+		return nil
+	}
+
+	return &b
+}
+
+// readTime parses an RFC 3339 timestamp from the query string, returning nil
+// when key is not in it.
+func (app *application) readTime(qs url.Values, key string, v *validator.Validator) *time.Time {
+	s := qs.Get(key)
+	if s == "" {
+		return nil
+	}
+
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		v.AddError(key, "must be an RFC 3339 timestamp")
+		return nil
+	}
+
+	return &t
+}
+
+// readCursor switches a listing into keyset pagination when the query string
+// contains a cursor (or its alias after). An empty cursor starts from the
+// first page.
+func (app *application) readCursor(qs url.Values, sort string, v *validator.Validator) *data.Cursor {
+	key := "cursor"
+	if !qs.Has(key) {
+		key = "after"
+	}
+
+	s := qs.Get(key)
+	if s == "" {
+		return &data.Cursor{Sort: sort}
+	}
+
+	cursor, err := app.decodeCursor(s)
+	if err != nil {
+		v.AddError(key, "invalid cursor")
+		return nil
+	}
+
+	v.Check(cursor.Sort == sort, key, "does not match the sort parameter")
+	return cursor
+}
+
+func (app *application) encodeCursor(cursor *data.Cursor) (string, error) {
+	js, err := json.Marshal(cursor)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(js)
+
+	mac := hmac.New(sha256.New, []byte(app.config.cursor.secret))
+	mac.Write([]byte(payload))
+	signature := base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+
+	return payload + "." + signature, nil
+}
+
+func (app *application) decodeCursor(s string) (*data.Cursor, error) {
+	payload, signature, found := strings.Cut(s, ".")
+	if !found {
+		return nil, errors.New("malformed cursor")
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, []byte(app.config.cursor.secret))
+	mac.Write([]byte(payload))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, errors.New("invalid cursor signature")
+	}
+
+	js, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor data.Cursor
+	err = json.Unmarshal(js, &cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cursor, nil
+}
+
 func (app *application) background(fn func()) {
 	app.wg.Add(1)
 	go func() {
@@ -131,3 +269,21 @@ func (app *application) background(fn func()) {
 		fn()
 	}()
 }
+
+// periodically runs fn every interval in the background until the server
+// shuts down.
+func (app *application) periodically(interval time.Duration, fn func()) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				fn()
+			case <-app.quit:
+				return
+			}
+		}
+	})
+}
@@ -0,0 +1,335 @@
+package main
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/jwt"
+)
+
+const (
+	tokenFormatOpaque = "opaque"
+	tokenFormatJWT    = "jwt"
+
+	jwtIssuer = "greenlight"
+)
+
+// accessClaims are the claims of a signed authentication token. They are a
+// snapshot of the user taken when the token was issued, which is what lets
+// authenticate trust them without a database lookup.
+type accessClaims struct {
+	jwt.Claims
+	Activated   bool             `json:"activated"`
+	Admin       bool             `json:"admin,omitempty"`
+	Suspended   bool             `json:"suspended,omitempty"`
+	MFAEnabled  bool             `json:"mfa,omitempty"`
+	Permissions data.Permissions `json:"permissions"`
+	// MFARequired lists the permissions of the user that cannot be used
+	// until they enable two-factor authentication.
+	MFARequired data.Permissions `json:"mfa_required,omitempty"`
+}
+
+func (app *application) jwtEnabled() bool {
+	return app.config.tokens.format == tokenFormatJWT
+}
+
+// signAccessToken replaces the plaintext of a newly issued authentication
+// token with a signed JWT when signed tokens are enabled. The opaque token is
+// still stored, so that the session shows up in GET /v1/users/sessions and
+// can be refreshed and revoked as usual; the JWT is tied to it by its jti,
+// the base64url encoded hash of the opaque token.
+func (app *application) signAccessToken(token *data.Token) error {
+	if !app.jwtEnabled() {
+		return nil
+	}
+
+	user, err := app.models.Users.Get(token.UserID)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := app.models.Permissions.GetAllForUser(user.ID)
+	if err != nil {
+		return err
+	}
+
+	var mfaRequired data.Permissions
+	if !user.MFAEnabled {
+		required, err := app.models.Permissions.GetRequiringMFA()
+		if err != nil {
+			return err
+		}
+		for _, code := range permissions {
+			if required.Include(code) {
+				mfaRequired = append(mfaRequired, code)
+			}
+		}
+	}
+
+	claims := accessClaims{
+		Claims: jwt.Claims{
+			Issuer:    jwtIssuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			ID:        base64.RawURLEncoding.EncodeToString(token.Hash),
+			IssuedAt:  jwt.NumericDate(time.Now()),
+			ExpiresAt: token.Expiry.Unix(),
+		},
+		Activated:   user.Activated,
+		Admin:       user.Admin,
+		Suspended:   user.Suspended,
+		MFAEnabled:  user.MFAEnabled,
+		Permissions: permissions,
+		MFARequired: mfaRequired,
+	}
+
+	token.Plaintext, err = app.jwtKeys.Sign(claims)
+	return err
+}
+
+// authenticateJWT authenticates a request made with a signed token from its
+// claims alone.
+func (app *application) authenticateJWT(w http.ResponseWriter, r *http.Request, token string, next http.Handler) {
+	var claims accessClaims
+
+	err := app.jwtKeys.Verify(token, &claims)
+	if err == nil {
+		err = claims.Valid(jwtIssuer, time.Now())
+	}
+	if err != nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	id, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+	hash, err := base64.RawURLEncoding.DecodeString(claims.ID)
+	if err != nil {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	if app.revocations.revoked(id, &claims) {
+		app.invalidAuthenticationTokenResponse(w, r)
+		return
+	}
+
+	user := &data.User{
+		ID:         id,
+		Activated:  claims.Activated,
+		Admin:      claims.Admin,
+		Suspended:  claims.Suspended,
+		MFAEnabled: claims.MFAEnabled,
+	}
+
+	r = app.contextSetUser(r, user)
+	r = app.contextSetToken(r, hash)
+	r = app.contextSetJWTClaims(r, &claims)
+
+	next.ServeHTTP(w, r)
+}
+
+// loadJWTUser replaces the partial user built from the claims of a signed
+// token with the full record, for the account endpoints that read or update
+// it.
+func (app *application) loadJWTUser(r *http.Request) (*http.Request, error) {
+	if app.contextGetJWTClaims(r) == nil {
+		return r, nil
+	}
+
+	user, err := app.models.Users.Get(app.contextGetUser(r).ID)
+	if err != nil {
+		return r, err
+	}
+	return app.contextSetUser(r, user), nil
+}
+
+// revokeJWT revokes the signed token with the given jti until it expires.
+func (app *application) revokeJWT(jti string, expiry time.Time) error {
+	if !app.jwtEnabled() {
+		return nil
+	}
+
+	err := app.models.Revocations.RevokeToken(jti, expiry)
+	if err != nil {
+		return err
+	}
+	app.revocations.revokeToken(jti, expiry)
+	return nil
+}
+
+// revokeSessionJWTs revokes the signed tokens of the sessions of the user
+// that match. It must be called before the sessions are deleted.
+func (app *application) revokeSessionJWTs(userID int64, match func(*data.Session) bool) error {
+	if !app.jwtEnabled() {
+		return nil
+	}
+
+	sessions, err := app.models.Tokens.GetSessionsForUser(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, session := range sessions {
+		if match(session) {
+			err = app.revokeJWT(base64.RawURLEncoding.EncodeToString(session.Hash), session.Expiry)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// revokeTokenJWTs revokes the signed tokens of authentication tokens that
+// have been deleted.
+func (app *application) revokeTokenJWTs(tokens []*data.Token) error {
+	for _, token := range tokens {
+		err := app.revokeJWT(base64.RawURLEncoding.EncodeToString(token.Hash), token.Expiry)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeUserJWTs revokes every signed token issued to the user so far,
+// including those of sessions that were already rotated away.
+func (app *application) revokeUserJWTs(userID int64) error {
+	if !app.jwtEnabled() {
+		return nil
+	}
+
+	now := time.Now()
+	err := app.models.Revocations.RevokeUser(userID, now)
+	if err != nil {
+		return err
+	}
+	app.revocations.revokeUser(userID, now)
+	return nil
+}
+
+// revokeUsersJWTs revokes the signed tokens of the users lookup returns. The
+// users are only looked up when signed tokens are in use.
+func (app *application) revokeUsersJWTs(lookup func() ([]int64, error)) error {
+	if !app.jwtEnabled() {
+		return nil
+	}
+
+	userIDs, err := lookup()
+	if err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		err = app.revokeUserJWTs(userID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncRevocations periodically loads the revocations made by other instances
+// of the API, and forgets those of tokens that have expired anyway.
+func (app *application) syncRevocations() {
+	app.periodically(app.config.jwt.revocationSync, func() {
+		_, err := app.models.Revocations.DeleteExpired(app.config.tokens.accessTTL)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+
+		revocations, err := app.models.Revocations.GetAll(app.config.tokens.accessTTL)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+			return
+		}
+		app.revocations.merge(revocations, app.config.tokens.accessTTL)
+	})
+}
+
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	// The envelope doubles as the JWK Set document of RFC 7517.
+	err := app.writeJSON(w, http.StatusOK, envelope{"keys": app.jwtKeys.PublicKeys()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// revocationList is the in-memory copy of the revoked signed tokens that
+// authenticate checks on every request.
+type revocationList struct {
+	mu     sync.RWMutex
+	tokens map[string]time.Time
+	users  map[int64]time.Time
+}
+
+func newRevocationList() *revocationList {
+	return &revocationList{
+		tokens: make(map[string]time.Time),
+		users:  make(map[int64]time.Time),
+	}
+}
+
+func (l *revocationList) revokeToken(jti string, expiry time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens[jti] = expiry
+}
+
+func (l *revocationList) revokeUser(userID int64, at time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if at.After(l.users[userID]) {
+		l.users[userID] = at
+	}
+}
+
+// revoked reports whether the token with the given claims was revoked. Tokens
+// issued in the same millisecond as a revocation of all of a user's tokens
+// are let through, as that is the resolution of iat and the user is usually
+// signed in again straight away, e.g. after changing their password.
+func (l *revocationList) revoked(userID int64, claims *accessClaims) bool {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	if _, ok := l.tokens[claims.ID]; ok {
+		return true
+	}
+	at, ok := l.users[userID]
+	return ok && claims.Issued().Before(at.Truncate(time.Millisecond))
+}
+
+// merge adds the revocations loaded from the database and forgets those that
+// no longer apply to tokens valid for at most maxAge.
+func (l *revocationList) merge(revocations *data.Revocations, maxAge time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for jti, expiry := range revocations.Tokens {
+		l.tokens[jti] = expiry
+	}
+	for jti, expiry := range l.tokens {
+		if !expiry.After(now) {
+			delete(l.tokens, jti)
+		}
+	}
+
+	for userID, at := range revocations.Users {
+		if at.After(l.users[userID]) {
+			l.users[userID] = at
+		}
+	}
+	for userID, at := range l.users {
+		if !at.After(now.Add(-maxAge)) {
+			delete(l.users, userID)
+		}
+	}
+}
@@ -0,0 +1,358 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/data/mock"
+	"github.com/IfedayoAwe/greenlight/internal/jwt"
+)
+
+func newTestJWTKey(t *testing.T, id, alg string, b byte) *jwt.Key {
+	var key *jwt.Key
+	var err error
+	switch alg {
+	case jwt.EdDSA:
+		key, err = jwt.NewEdDSAKey(id, bytes.Repeat([]byte{b}, 32))
+	default:
+		key, err = jwt.NewHS256Key(id, bytes.Repeat([]byte{b}, 32))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestJWTApplication(t *testing.T, keys ...*jwt.Key) *application {
+	app := newTestApplication(t)
+	app.config.tokens.format = tokenFormatJWT
+
+	if len(keys) == 0 {
+		keys = []*jwt.Key{newTestJWTKey(t, "2024-06", jwt.EdDSA, 1), newTestJWTKey(t, "2024-01", jwt.HS256, 2)}
+	}
+
+	keySet, err := jwt.NewKeySet(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	app.jwtKeys = keySet
+
+	return app
+}
+
+// signTestJWT signs a token for the current session of the mock user with
+// the given id.
+func signTestJWT(t *testing.T, app *application, userID int64, expiry time.Time) string {
+	hash := sha256.Sum256([]byte("HTE34GKUHNDUSJ3QRUT6IKWKRI"))
+	token := &data.Token{UserID: userID, Hash: hash[:], Expiry: expiry}
+
+	err := app.signAccessToken(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token.Plaintext
+}
+
+// tamperTestJWT flips a bit of the signature of token.
+func tamperTestJWT(t *testing.T, token string) string {
+	i := strings.LastIndex(token, ".")
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature[0] ^= 1
+	return token[:i+1] + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestJWTAuthentication(t *testing.T) {
+	app := newTestJWTApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	valid := signTestJWT(t, app, 1, time.Now().Add(time.Hour))
+	readOnly := signTestJWT(t, app, 3, time.Now().Add(time.Hour))
+	expired := signTestJWT(t, app, 1, time.Now().Add(-time.Minute))
+	tampered := tamperTestJWT(t, valid)
+
+	// A token signed with the previous key, which is kept to verify the
+	// tokens issued before the rotation.
+	rotated := signTestJWT(t, newTestJWTApplication(t, newTestJWTKey(t, "2024-01", jwt.HS256, 2)), 1, time.Now().Add(time.Hour))
+	unknownKey := signTestJWT(t, newTestJWTApplication(t, newTestJWTKey(t, "2023-01", jwt.HS256, 3)), 1, time.Now().Add(time.Hour))
+	forged := signTestJWT(t, newTestJWTApplication(t, newTestJWTKey(t, "2024-01", jwt.HS256, 3)), 1, time.Now().Add(time.Hour))
+
+	app.models.Permissions = &mock.MockPermissionModel{RequiringMFA: data.Permissions{"movies:write"}}
+	mfaRequired := signTestJWT(t, app, 1, time.Now().Add(time.Hour))
+	app.models.Permissions = &mock.MockPermissionModel{}
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Valid", http.MethodGet, "/v1/movies/1", valid, http.StatusOK, []byte("Test Movie")},
+		{"RotatedKey", http.MethodGet, "/v1/movies/1", rotated, http.StatusOK, []byte("Test Movie")},
+		{"Opaque", http.MethodGet, "/v1/movies/1", "HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusOK, []byte("Test Movie")},
+		{"NotPermitted", http.MethodPost, "/v1/movies", readOnly, http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"MFARequired", http.MethodPost, "/v1/movies", mfaRequired, http.StatusForbidden, []byte("two-factor authentication enabled")},
+		{"Expired", http.MethodGet, "/v1/movies/1", expired, http.StatusUnauthorized, []byte("invalid or missing authentication token")},
+		{"Tampered", http.MethodGet, "/v1/movies/1", tampered, http.StatusUnauthorized, []byte("invalid or missing authentication token")},
+		{"UnknownKey", http.MethodGet, "/v1/movies/1", unknownKey, http.StatusUnauthorized, []byte("invalid or missing authentication token")},
+		{"Forged", http.MethodGet, "/v1/movies/1", forged, http.StatusUnauthorized, []byte("invalid or missing authentication token")},
+		{"Sessions", http.MethodGet, "/v1/users/sessions", valid, http.StatusOK, []byte("\"current\": true")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestJWTIssueAndRevoke(t *testing.T) {
+	app := newTestJWTApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	payload := `{"email": "olalekanawe99@gmail.com", "password": "1234567890"}`
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/tokens/authentication", strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, _, body := ts.do(t, req)
+	if code != http.StatusCreated {
+		t.Fatalf("want %d; got %d", http.StatusCreated, code)
+	}
+
+	var response struct {
+		AuthenticationToken struct {
+			Token string `json:"token"`
+		} `json:"authentication_token"`
+	}
+	unMarshal(t, body, &response)
+
+	token := response.AuthenticationToken.Token
+	if strings.Count(token, ".") != 2 {
+		t.Fatalf("want a signed token; got %q", token)
+	}
+
+	steps := []struct {
+		name     string
+		method   string
+		urlPath  string
+		wantCode int
+	}{
+		{"BeforeLogout", http.MethodGet, "/v1/movies/1", http.StatusOK},
+		{"Logout", http.MethodDelete, "/v1/users/logout", http.StatusOK},
+		{"AfterLogout", http.MethodGet, "/v1/movies/1", http.StatusUnauthorized},
+	}
+
+	for _, step := range steps {
+		req, err := http.NewRequest(step.method, ts.URL+step.urlPath, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+
+		code, _, _ := ts.do(t, req)
+		if code != step.wantCode {
+			t.Errorf("%s: want %d; got %d", step.name, step.wantCode, code)
+		}
+	}
+}
+
+func TestJWTRefreshRevokes(t *testing.T) {
+	tests := []struct {
+		name         string
+		refreshToken string
+		wantCode     int
+	}{
+		{"Rotated", "RFR34GKUHNDUSJ3QRUT6IKWKRI", http.StatusCreated},
+		{"Reused", "RFR34GKUHNDUSJ3QRUT6IKWKRU", http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestJWTApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			// The signed token of the authentication token the refresh deletes.
+			hash := sha256.Sum256([]byte(mock.MockPreviousAccessToken))
+			previous := &data.Token{UserID: 1, Hash: hash[:], Expiry: time.Now().Add(time.Hour)}
+			err := app.signAccessToken(previous)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			payload := `{"refresh_token": "` + tt.refreshToken + `"}`
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/tokens/refresh", strings.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, _, _ := ts.do(t, req)
+			if code != tt.wantCode {
+				t.Fatalf("want %d; got %d", tt.wantCode, code)
+			}
+
+			req, err = http.NewRequest(http.MethodGet, ts.URL+"/v1/movies/1", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+previous.Plaintext)
+
+			code, _, _ = ts.do(t, req)
+			if code != http.StatusUnauthorized {
+				t.Errorf("want %d; got %d", http.StatusUnauthorized, code)
+			}
+		})
+	}
+}
+
+func TestJWTPermissionChangesRevoke(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		urlPath string
+		payload string
+	}{
+		{"RemoveUserPermission", http.MethodDelete, "/v1/admin/users/1/permissions/movies:write", ""},
+		{"RemoveUserRole", http.MethodDelete, "/v1/admin/users/1/roles/contributor", ""},
+		{"UpdatePermission", http.MethodPatch, "/v1/admin/permissions/movies:write", `{"requires_mfa": true}`},
+		{"UpdateRole", http.MethodPatch, "/v1/admin/roles/2", `{"permissions": ["movies:read"]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := newTestJWTApplication(t)
+			ts := newTestServer(t, app.routes())
+			defer ts.Close()
+
+			token := signTestJWT(t, app, 1, time.Now().Add(time.Hour))
+
+			steps := []struct {
+				name     string
+				method   string
+				urlPath  string
+				payload  string
+				wantCode int
+			}{
+				{"Change", tt.method, tt.urlPath, tt.payload, http.StatusOK},
+				{"AfterChange", http.MethodGet, "/v1/movies/1", "", http.StatusUnauthorized},
+			}
+
+			for _, step := range steps {
+				req, err := http.NewRequest(step.method, ts.URL+step.urlPath, strings.NewReader(step.payload))
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+
+				code, _, _ := ts.do(t, req)
+				if code != step.wantCode {
+					t.Errorf("%s: want %d; got %d", step.name, step.wantCode, code)
+				}
+			}
+		})
+	}
+}
+
+func TestJWTRevocationList(t *testing.T) {
+	now := time.Now()
+
+	list := newRevocationList()
+	list.revokeToken("revoked", now.Add(time.Hour))
+	list.revokeUser(2, now)
+
+	tests := []struct {
+		name   string
+		userID int64
+		claims jwt.Claims
+		want   bool
+	}{
+		{"NotRevoked", 1, jwt.Claims{ID: "valid", IssuedAt: jwt.NumericDate(now)}, false},
+		{"RevokedToken", 1, jwt.Claims{ID: "revoked", IssuedAt: jwt.NumericDate(now)}, true},
+		{"IssuedBeforeUserRevocation", 2, jwt.Claims{ID: "valid", IssuedAt: jwt.NumericDate(now.Add(-time.Minute))}, true},
+		{"IssuedJustBeforeUserRevocation", 2, jwt.Claims{ID: "valid", IssuedAt: jwt.NumericDate(now.Add(-2 * time.Millisecond))}, true},
+		{"IssuedWithUserRevocation", 2, jwt.Claims{ID: "valid", IssuedAt: jwt.NumericDate(now)}, false},
+		{"IssuedAfterUserRevocation", 2, jwt.Claims{ID: "valid", IssuedAt: jwt.NumericDate(now.Add(time.Millisecond))}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := list.revoked(tt.userID, &accessClaims{Claims: tt.claims}); got != tt.want {
+				t.Errorf("want %t; got %t", tt.want, got)
+			}
+		})
+	}
+
+	// Revocations of expired tokens are forgotten when merging.
+	list.revokeToken("expired", now.Add(-time.Second))
+	list.merge(&data.Revocations{Tokens: map[string]time.Time{"remote": now.Add(time.Hour)}}, time.Hour)
+
+	if !list.revoked(1, &accessClaims{Claims: jwt.Claims{ID: "remote"}}) {
+		t.Error("want the revocation loaded from the database to apply")
+	}
+	if _, ok := list.tokens["expired"]; ok {
+		t.Error("want the revocation of an expired token to be forgotten")
+	}
+}
+
+func TestJWKS(t *testing.T) {
+	app := newTestJWTApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	req, err := http.NewRequest(http.MethodGet, ts.URL+"/.well-known/jwks.json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code, header, body := ts.do(t, req)
+	if contentType := header.Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("want %q; got %q", "application/json", contentType)
+	}
+
+	if code != http.StatusOK {
+		t.Errorf("want %d; got %d", http.StatusOK, code)
+	}
+
+	for _, want := range [][]byte{[]byte("\"kid\": \"2024-06\""), []byte("\"kty\": \"OKP\""), []byte("\"crv\": \"Ed25519\"")} {
+		if !bytes.Contains(body, want) {
+			t.Errorf("want body to contain %q", want)
+		}
+	}
+
+	// HS256 secrets must never be published.
+	if bytes.Contains(body, []byte("2024-01")) {
+		t.Error("want the HS256 key to be left out")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestLists(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	owner := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"
+	reader := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"List", http.MethodGet, "/v1/lists", reader, "", http.StatusOK, []byte("\"name\": \"Best of 2003\"")},
+		{"ListInvalidSort", http.MethodGet, "/v1/lists?sort=user_id", reader, "", http.StatusUnprocessableEntity, []byte("invalid sort value")},
+		{"ListUnauthenticated", http.MethodGet, "/v1/lists", "", "", http.StatusUnauthorized, []byte("you must be authenticated to access this resource")},
+		{"Create", http.MethodPost, "/v1/lists", reader, `{"name": "Best of 1990s noir", "public": true}`, http.StatusCreated, []byte("\"movie_count\": 0")},
+		{"CreateReadOnlyAPIKey", http.MethodPost, "/v1/lists", "ApiKey AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", `{"name": "Key list"}`, http.StatusForbidden, []byte("this resource cannot be accessed with an api key")},
+		{"CreateUnauthenticated", http.MethodPost, "/v1/lists", "", `{"name": "Anonymous list"}`, http.StatusUnauthorized, []byte("you must be authenticated to access this resource")},
+		{"CreateNoName", http.MethodPost, "/v1/lists", reader, `{"description": "Untitled"}`, http.StatusUnprocessableEntity, []byte("must be provided")},
+		{"ShowPublic", http.MethodGet, "/v1/lists/1", reader, "", http.StatusOK, []byte("\"note\": \"Still holds up.\"")},
+		{"ShowPublicWatchFlags", http.MethodGet, "/v1/lists/1", reader, "", http.StatusOK, []byte("\"in_watchlist\": true")},
+		{"ShowPrivateOwner", http.MethodGet, "/v1/lists/2", owner, "", http.StatusOK, []byte("\"public\": false")},
+		{"ShowPrivateOther", http.MethodGet, "/v1/lists/2", reader, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"Update", http.MethodPatch, "/v1/lists/1", owner, `{"public": false}`, http.StatusOK, []byte("\"version\": 2")},
+		{"UpdateNotOwner", http.MethodPatch, "/v1/lists/1", reader, `{"public": false}`, http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"UpdatePrivateNotOwner", http.MethodPatch, "/v1/lists/2", reader, `{"public": true}`, http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"Delete", http.MethodDelete, "/v1/lists/2", owner, "", http.StatusOK, []byte("list successfully deleted")},
+		{"AddMovie", http.MethodPost, "/v1/lists/2/movies", owner, `{"movie_id": 1, "note": "Every time."}`, http.StatusCreated, []byte("\"position\": 1")},
+		{"AddDuplicateMovie", http.MethodPost, "/v1/lists/1/movies", owner, `{"movie_id": 1}`, http.StatusConflict, []byte("this movie is already on the list")},
+		{"AddUnknownMovie", http.MethodPost, "/v1/lists/2/movies", owner, `{"movie_id": 99}`, http.StatusUnprocessableEntity, []byte("must be the id of an existing movie")},
+		{"AddMovieNotOwner", http.MethodPost, "/v1/lists/1/movies", reader, `{"movie_id": 2}`, http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"UpdateEntry", http.MethodPatch, "/v1/lists/1/movies/1", owner, `{"note": "Better every time."}`, http.StatusOK, []byte("\"note\": \"Better every time.\"")},
+		{"UpdateEntryInvalidPosition", http.MethodPatch, "/v1/lists/1/movies/1", owner, `{"position": 0}`, http.StatusUnprocessableEntity, []byte("must be a positive integer")},
+		{"UpdateEntryNotFound", http.MethodPatch, "/v1/lists/1/movies/2", owner, `{"note": "Missing"}`, http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"AddMovieReadOnlyAPIKey", http.MethodPost, "/v1/lists/1/movies", "ApiKey AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", `{"movie_id": 2}`, http.StatusForbidden, []byte("this resource cannot be accessed with an api key")},
+		{"ShowAPIKey", http.MethodGet, "/v1/lists/1", "ApiKey AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", "", http.StatusOK, []byte("\"name\": \"Best of 2003\"")},
+		{"RemoveMovie", http.MethodDelete, "/v1/lists/1/movies/1", owner, "", http.StatusOK, []byte("movie successfully removed from the list")},
+		{"RemoveMovieNotOnList", http.MethodDelete, "/v1/lists/2/movies/1", owner, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, strings.NewReader(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.token != "" {
+				req.Header.Set("Authorization", tt.token)
+			}
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
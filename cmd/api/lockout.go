@@ -0,0 +1,265 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/tomasen/realip"
+)
+
+// maxAttemptDelay caps the delay enforced between failed attempts before a
+// lockout kicks in.
+const maxAttemptDelay = time.Minute
+
+type attemptKey struct {
+	kind string
+	key  string
+}
+
+func emailAttempt(email string) attemptKey {
+	return attemptKey{kind: data.AttemptEmail, key: strings.ToLower(email)}
+}
+
+func magicLinkAttempt(email string) attemptKey {
+	return attemptKey{kind: data.AttemptMagicLink, key: strings.ToLower(email)}
+}
+
+func ipAttempt(r *http.Request) attemptKey {
+	return attemptKey{kind: data.AttemptIP, key: realip.FromRequest(r)}
+}
+
+// checkAttempts writes a 429 response with a Retry-After header and returns
+// false if any of keys is locked out or has to wait before its next attempt.
+func (app *application) checkAttempts(w http.ResponseWriter, r *http.Request, keys ...attemptKey) bool {
+	if !app.config.lockout.enabled {
+		return true
+	}
+
+	var wait time.Duration
+	now := time.Now()
+
+	for _, key := range keys {
+		attempts, err := app.models.Attempts.Get(key.kind, key.key)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return false
+		}
+
+		if d := app.retryAfter(attempts, now); d > wait {
+			wait = d
+		}
+	}
+
+	if wait > 0 {
+		app.tooManyAttemptsResponse(w, r, wait)
+		return false
+	}
+	return true
+}
+
+// retryAfter returns how long to wait before the next attempt: until the end
+// of a lockout, or a delay that starts at a second once lockout.delayAfter
+// attempts have failed and doubles with every further failure.
+func (app *application) retryAfter(attempts *data.LoginAttempts, now time.Time) time.Duration {
+	if attempts.LockedUntil.After(now) {
+		return attempts.LockedUntil.Sub(now)
+	}
+
+	excess := attempts.Failures - app.config.lockout.delayAfter
+	if excess < 0 {
+		return 0
+	}
+
+	delay := maxAttemptDelay
+	if excess < 6 {
+		delay = time.Second << excess
+	}
+
+	return attempts.LastFailureAt.Add(delay).Sub(now)
+}
+
+// recordFailedAttempt counts a failed attempt against each of keys, locking
+// them once they reach their threshold. Failures to record are only logged so
+// that they never change the response.
+func (app *application) recordFailedAttempt(r *http.Request, keys ...attemptKey) {
+	if !app.config.lockout.enabled {
+		return
+	}
+
+	for _, key := range keys {
+		threshold := app.config.lockout.threshold
+		if key.kind == data.AttemptIP {
+			threshold = app.config.lockout.ipThreshold
+		}
+
+		attempts, err := app.models.Attempts.RecordFailure(key.kind, key.key, threshold, app.config.lockout.duration)
+		if err != nil {
+			app.logError(r, err)
+			continue
+		}
+
+		if attempts.LockedUntil.IsZero() {
+			continue
+		}
+
+		app.logSecurityEvent(r, "lockout", map[string]string{
+			"kind":         key.kind,
+			"key":          key.key,
+			"failures":     strconv.Itoa(attempts.Failures),
+			"locked_until": attempts.LockedUntil.Format(time.RFC3339),
+		})
+
+		if key.kind == data.AttemptEmail {
+			app.sendUnlockEmail(r, key.key)
+		}
+	}
+}
+
+func (app *application) resetAttempts(r *http.Request, key attemptKey) {
+	if !app.config.lockout.enabled {
+		return
+	}
+
+	err := app.models.Attempts.Reset(key.kind, key.key)
+	if err != nil {
+		app.logError(r, err)
+	}
+}
+
+// logSecurityEvent writes a structured log entry for security sensitive
+// events such as lockouts.
+func (app *application) logSecurityEvent(r *http.Request, event string, properties map[string]string) {
+	properties["event"] = event
+	properties["ip"] = realip.FromRequest(r)
+	app.logger.PrintInfo("security event", properties)
+}
+
+// sendUnlockEmail emails the owner of a locked email address a token that
+// lifts the lockout, so that an attacker cannot keep them out of their account.
+func (app *application) sendUnlockEmail(r *http.Request, email string) {
+	user, err := app.models.Users.GetByEmail(email)
+	if err != nil {
+		if !errors.Is(err, data.ErrRecordNotFound) {
+			app.logError(r, err)
+		}
+		return
+	}
+
+	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeUnlock, r)
+	if err != nil {
+		app.logError(r, err)
+		return
+	}
+
+	app.background(func() {
+		data := map[string]interface{}{
+			"unlockToken":    token.Plaintext,
+			"lockoutMinutes": int(app.config.lockout.duration.Minutes()),
+		}
+
+		err := app.mailer.Send(user.Email, "account_locked.html", data)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+}
+
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeUnlock, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
+			v.AddError("token", "invalid or expired unlock token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Attempts.Reset(data.AttemptEmail, strings.ToLower(user.Email))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeUnlock, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logSecurityEvent(r, "unlock", map[string]string{
+		"kind": data.AttemptEmail,
+		"key":  strings.ToLower(user.Email),
+	})
+	app.auditAs(r, &user.ID, "user.unlock", "user", user.ID, nil, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "your account was successfully unlocked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) adminUnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	user, ok := app.readUserParam(w, r)
+	if !ok {
+		return
+	}
+
+	err := app.models.Attempts.Reset(data.AttemptEmail, strings.ToLower(user.Email))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.logSecurityEvent(r, "unlock", map[string]string{
+		"kind":     data.AttemptEmail,
+		"key":      strings.ToLower(user.Email),
+		"admin_id": strconv.FormatInt(app.contextGetUser(r).ID, 10),
+	})
+	app.audit(r, "admin.user.unlock", "user", user.ID, nil, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user account successfully unlocked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// sweepLoginAttempts periodically forgets failed attempts that are too old to
+// count towards a lockout.
+func (app *application) sweepLoginAttempts() {
+	app.periodically(app.config.lockout.duration, func() {
+		_, err := app.models.Attempts.DeleteStale(app.config.lockout.duration)
+		if err != nil {
+			app.logger.PrintError(err, nil)
+		}
+	})
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestLockout(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name           string
+		method         string
+		urlPath        string
+		payload        string
+		ip             string
+		wantCode       int
+		wantBody       []byte
+		wantRetryAfter bool
+	}{
+		{"LockedEmail", http.MethodPost, "/v1/tokens/authentication", `{"email": "locked@gmail.com", "password": "1234567890"}`, "", http.StatusTooManyRequests, []byte("too many failed attempts, please try again later"), true},
+		{"DelayedEmail", http.MethodPost, "/v1/tokens/authentication", `{"email": "slow@gmail.com", "password": "1234567890"}`, "", http.StatusTooManyRequests, []byte("too many failed attempts, please try again later"), true},
+		{"LockedIP", http.MethodPost, "/v1/tokens/authentication", `{"email": "olalekanawe99@gmail.com", "password": "1234567890"}`, "203.0.113.9", http.StatusTooManyRequests, []byte("too many failed attempts, please try again later"), true},
+		{"LockedIPActivation", http.MethodPut, "/v1/users/activated", `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRJ"}`, "203.0.113.9", http.StatusTooManyRequests, []byte("too many failed attempts, please try again later"), true},
+		{"LockedIPPasswordReset", http.MethodPut, "/v1/users/password", `{"password": "pa5555word", "token": "HTE34GKUHNDUSJ3QRUT6IKWKRJ"}`, "203.0.113.9", http.StatusTooManyRequests, []byte("too many failed attempts, please try again later"), true},
+		{"NotLocked", http.MethodPost, "/v1/tokens/authentication", `{"email": "olalekanawe99@gmail.com", "password": "1234567890"}`, "198.51.100.7", http.StatusCreated, []byte("authentication_token"), false},
+		{"Unlock", http.MethodPut, "/v1/users/unlocked", `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRI"}`, "", http.StatusOK, []byte("your account was successfully unlocked"), false},
+		{"UnlockInvalidToken", http.MethodPut, "/v1/users/unlocked", `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRX"}`, "", http.StatusUnprocessableEntity, []byte("invalid or expired unlock token"), false},
+		{"UnlockLockedIP", http.MethodPut, "/v1/users/unlocked", `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRI"}`, "203.0.113.9", http.StatusTooManyRequests, []byte("too many failed attempts, please try again later"), true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Add("Content-Type", "application/json")
+			if tt.ip != "" {
+				req.Header.Set("X-Real-Ip", tt.ip)
+			}
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if retryAfter := header.Get("Retry-After"); (retryAfter != "") != tt.wantRetryAfter {
+				t.Errorf("want Retry-After header %t; got %q", tt.wantRetryAfter, retryAfter)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestAdminUnlockUser(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		urlPath  string
+		token    string
+	}{
+		{"Unlocked", http.StatusOK, []byte("user account successfully unlocked"), "/v1/admin/users/3/unlock", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"NotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "/v1/admin/users/9/unlock", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"NotAdmin", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "/v1/admin/users/3/unlock", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -2,7 +2,9 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
 	"database/sql"
+	"encoding/hex"
 	"errors"
 	"expvar"
 	"flag"
@@ -16,6 +18,7 @@ import (
 
 	"github.com/IfedayoAwe/greenlight/internal/data"
 	"github.com/IfedayoAwe/greenlight/internal/jsonlog"
+	"github.com/IfedayoAwe/greenlight/internal/jwt"
 	"github.com/IfedayoAwe/greenlight/internal/mailer"
 	_ "github.com/lib/pq"
 )
@@ -57,6 +60,49 @@ type config struct {
 	cors struct {
 		trustedOrigins []string
 	}
+	cursor struct {
+		secret string
+	}
+	problemJSON bool
+	conditional struct {
+		requireIfMatch bool
+	}
+	trash struct {
+		retention     time.Duration
+		sweepInterval time.Duration
+	}
+	tokens struct {
+		accessTTL  time.Duration
+		refreshTTL time.Duration
+		format     string
+	}
+	jwt struct {
+		keys           string
+		revocationSync time.Duration
+	}
+	oauth struct {
+		accessTTL time.Duration
+		codeTTL   time.Duration
+	}
+	lockout struct {
+		threshold   int
+		ipThreshold int
+		delayAfter  int
+		duration    time.Duration
+		enabled     bool
+	}
+	cache struct {
+		ttl        time.Duration
+		maxEntries int
+		enabled    bool
+	}
+	audit struct {
+		log bool
+	}
+	export struct {
+		dir string
+		ttl time.Duration
+	}
 }
 
 type application struct {
@@ -65,6 +111,13 @@ type application struct {
 	models data.Models
 	mailer mailer.Mailer
 	wg     sync.WaitGroup
+	// quit is closed when the server shuts down, to stop the tasks started
+	// with periodically.
+	quit chan struct{}
+	// jwtKeys and revocations are only used when authentication tokens
+	// are signed JWTs.
+	jwtKeys     *jwt.KeySet
+	revocations *revocationList
 }
 
 func main() {
@@ -94,6 +147,29 @@ func main() {
 	flag.StringVar(&cfg.smtp.password, "smtp-password", os.Getenv("SMTP_PASSWORD"), "SMTP password")
 	flag.StringVar(&cfg.smtp.sender, "smtp-sender", os.Getenv("SMTP_SENDER"), "SMTP sender")
 	flag.BoolVar(&cfg.smtp.enabled, "smtp-enabled", true, "Enable SMTP")
+	flag.StringVar(&cfg.cursor.secret, "cursor-secret", os.Getenv("GREENLIGHT_CURSOR_SECRET"), "Secret key used to sign pagination cursors")
+	flag.BoolVar(&cfg.problemJSON, "problem-json", false, "Always send errors as application/problem+json documents")
+	flag.BoolVar(&cfg.conditional.requireIfMatch, "require-if-match", false, "Require an If-Match header on movie updates and deletes")
+	flag.DurationVar(&cfg.trash.retention, "trash-retention", 30*24*time.Hour, "How long deleted movies are kept before being purged")
+	flag.DurationVar(&cfg.trash.sweepInterval, "trash-sweep-interval", time.Hour, "How often deleted movies are checked for purging")
+	flag.DurationVar(&cfg.tokens.accessTTL, "access-token-ttl", 24*time.Hour, "How long authentication tokens are valid for")
+	flag.DurationVar(&cfg.tokens.refreshTTL, "refresh-token-ttl", 30*24*time.Hour, "How long refresh tokens are valid for")
+	flag.StringVar(&cfg.tokens.format, "token-format", tokenFormatOpaque, "Format of authentication tokens (opaque|jwt)")
+	flag.StringVar(&cfg.jwt.keys, "jwt-keys", os.Getenv("GREENLIGHT_JWT_KEYS"), "Keys signed tokens are signed with as kid:alg:key entries, the first one signing (comma separated)")
+	flag.DurationVar(&cfg.jwt.revocationSync, "jwt-revocation-sync", 30*time.Second, "How often revoked signed tokens are loaded from the database")
+	flag.DurationVar(&cfg.oauth.accessTTL, "oauth-access-token-ttl", time.Hour, "How long access tokens issued to OAuth clients are valid for")
+	flag.DurationVar(&cfg.oauth.codeTTL, "oauth-code-ttl", 10*time.Minute, "How long OAuth authorization codes are valid for")
+	flag.IntVar(&cfg.lockout.threshold, "lockout-threshold", 10, "Failed sign in attempts for an email address before it is locked")
+	flag.IntVar(&cfg.lockout.ipThreshold, "lockout-ip-threshold", 100, "Failed sign in attempts from an IP address before it is locked")
+	flag.IntVar(&cfg.lockout.delayAfter, "lockout-delay-after", 3, "Failed sign in attempts after which every further attempt is delayed")
+	flag.DurationVar(&cfg.lockout.duration, "lockout-duration", 15*time.Minute, "How long lockouts last and failed attempts are remembered")
+	flag.BoolVar(&cfg.lockout.enabled, "lockout-enabled", true, "Enable brute-force protection of sign in and token endpoints")
+	flag.DurationVar(&cfg.cache.ttl, "cache-ttl", 30*time.Second, "How long token, permission and genre lookups are cached")
+	flag.IntVar(&cfg.cache.maxEntries, "cache-max-entries", 10000, "Maximum number of cached token and permission lookups each")
+	flag.BoolVar(&cfg.cache.enabled, "cache-enabled", true, "Enable caching of token, permission and genre lookups")
+	flag.BoolVar(&cfg.audit.log, "audit-log", false, "Also write audit events to the log")
+	flag.StringVar(&cfg.export.dir, "export-dir", "exports", "Directory personal data exports are written to")
+	flag.DurationVar(&cfg.export.ttl, "export-ttl", 24*time.Hour, "How long personal data exports can be downloaded")
 	flag.Func("cors-trusted-origins", "Trusted CORS origins (space separated)", func(val string) error {
 		cfg.cors.trustedOrigins = strings.Fields(val)
 		return nil
@@ -109,6 +185,51 @@ func main() {
 
 	logger := jsonlog.New(os.Stdout, jsonlog.LevelInfo)
 
+	// A retention of zero or less would purge every trashed movie on the next
+	// sweep, leaving nothing to restore.
+	if cfg.trash.retention <= 0 {
+		logger.PrintFatal(errors.New("-trash-retention must be greater than zero"), nil)
+	}
+	if cfg.trash.sweepInterval <= 0 {
+		logger.PrintFatal(errors.New("-trash-sweep-interval must be greater than zero"), nil)
+	}
+	if cfg.lockout.duration <= 0 {
+		logger.PrintFatal(errors.New("-lockout-duration must be greater than zero"), nil)
+	}
+
+	if cfg.cursor.secret == "" {
+		secret := make([]byte, 32)
+		_, err := rand.Read(secret)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		cfg.cursor.secret = hex.EncodeToString(secret)
+		logger.PrintInfo("no cursor secret provided, pagination cursors will not survive a restart", nil)
+	}
+
+	var jwtKeys *jwt.KeySet
+	switch {
+	case cfg.jwt.keys != "":
+		keys, err := jwt.ParseKeySet(cfg.jwt.keys)
+		if err != nil {
+			logger.PrintFatal(err, nil)
+		}
+		jwtKeys = keys
+	case cfg.tokens.format == tokenFormatJWT:
+		logger.PrintFatal(errors.New("-token-format=jwt requires -jwt-keys"), nil)
+	}
+	if cfg.tokens.format == tokenFormatJWT && cfg.jwt.revocationSync <= 0 {
+		logger.PrintFatal(errors.New("-jwt-revocation-sync must be greater than zero"), nil)
+	}
+	if cfg.tokens.format != tokenFormatOpaque && cfg.tokens.format != tokenFormatJWT {
+		logger.PrintFatal(fmt.Errorf("invalid token format %q", cfg.tokens.format), nil)
+	}
+
+	err := os.MkdirAll(cfg.export.dir, 0o700)
+	if err != nil {
+		logger.PrintFatal(err, nil)
+	}
+
 	db, err := openDB(cfg)
 	if err != nil {
 		logger.PrintFatal(err, nil)
@@ -117,6 +238,13 @@ func main() {
 
 	logger.PrintInfo("database connection pool established", nil)
 
+	var cache *data.AuthCache
+	var genreCache *data.GenreCache
+	if cfg.cache.enabled {
+		cache = data.NewAuthCache(cfg.cache.ttl, cfg.cache.maxEntries)
+		genreCache = data.NewGenreCache(cfg.cache.ttl)
+	}
+
 	expvar.NewString("version").Set(version)
 	// Publish the number of active goroutines.
 	expvar.Publish("goroutines", expvar.Func(func() interface{} {
@@ -126,16 +254,30 @@ func main() {
 	expvar.Publish("database", expvar.Func(func() interface{} {
 		return db.Stats()
 	}))
+	// Publish the hit and miss counters of the token and permission caches.
+	expvar.Publish("auth_cache", expvar.Func(cache.Stats))
+	// Publish the hit and miss counters of the genre index cache.
+	expvar.Publish("genre_cache", expvar.Func(genreCache.Stats))
 	// Publish the current Unix timestamp.
 	expvar.Publish("timestamp", expvar.Func(func() interface{} {
 		return time.Now().Unix()
 	}))
 
 	app := &application{
-		config: cfg,
-		logger: logger,
-		models: data.NewModels(db),
-		mailer: mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender, cfg.smtp.enabled),
+		config:      cfg,
+		logger:      logger,
+		models:      data.NewModels(db, cache, genreCache),
+		mailer:      mailer.New(cfg.smtp.host, cfg.smtp.port, cfg.smtp.username, cfg.smtp.password, cfg.smtp.sender, cfg.smtp.enabled),
+		jwtKeys:     jwtKeys,
+		revocations: newRevocationList(),
+		quit:        make(chan struct{}),
+	}
+
+	app.sweepTrash()
+	app.sweepLoginAttempts()
+	app.sweepExports()
+	if app.jwtEnabled() {
+		app.syncRevocations()
 	}
 
 	err = app.serve()
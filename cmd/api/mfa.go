@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/totp"
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+)
+
+const totpIssuer = "Greenlight"
+
+func (app *application) enrolTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.MFA.Enrol(user.ID, secret)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrMFAEnabled):
+			app.mfaEnabledResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	env := envelope{"totp": envelope{
+		"secret":      totp.EncodeSecret(secret),
+		"otpauth_uri": totp.URI(totpIssuer, user.Email, secret),
+	}}
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateMFACode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	secret, err := app.models.MFA.Get(user.ID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("code", "two-factor authentication enrolment has not been started")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if secret.Confirmed {
+		app.mfaEnabledResponse(w, r)
+		return
+	}
+
+	step, ok := totp.Validate(secret.Secret, input.Code, time.Now())
+	if !ok {
+		app.invalidMFACodeResponse(w, r)
+		return
+	}
+
+	recoveryCodes, err := data.GenerateRecoveryCodes()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.MFA.Confirm(user.ID, step, recoveryCodes)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrMFAEnabled):
+			app.mfaEnabledResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// Sessions signed in with only a password are no longer good enough.
+	err = app.deleteOtherSessions(user.ID, app.contextGetToken(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.audit(r, "user.mfa.enable", "user", user.ID, nil, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"recovery_codes": recoveryCodes}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	var input struct {
+		Code string `json:"code"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateMFACode(v, input.Code); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	ok, err := app.verifyMFACode(user.ID, input.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.invalidMFACodeResponse(w, r)
+		return
+	}
+
+	err = app.models.MFA.Delete(user.ID)
+	if err != nil && !errors.Is(err, data.ErrMFANotEnabled) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.audit(r, "user.mfa.disable", "user", user.ID, nil, nil)
+
+	env := envelope{"message": "two-factor authentication successfully disabled"}
+	err = app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMFAAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"mfa_token"`
+		Code           string `json:"code"`
+		DeviceName     string `json:"device_name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	data.ValidateMFACode(v, input.Code)
+	data.ValidateDeviceName(v, input.DeviceName)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeMFA, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
+			v.AddError("token", "invalid or expired mfa token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if !app.checkAttempts(w, r, emailAttempt(user.Email)) {
+		return
+	}
+
+	ok, err := app.verifyMFACode(user.ID, input.Code)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	if !ok {
+		app.recordFailedAttempt(r, emailAttempt(user.Email), ipAttempt(r))
+		app.invalidMFACodeResponse(w, r)
+		return
+	}
+
+	app.resetAttempts(r, emailAttempt(user.Email))
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeMFA, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.createSession(w, r, user, input.DeviceName, "mfa")
+}
+
+// verifyMFACode reports whether code is a current TOTP code or an unused
+// recovery code of the user, consuming it either way so it cannot be replayed.
+func (app *application) verifyMFACode(userID int64, code string) (bool, error) {
+	secret, err := app.models.MFA.Get(userID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+
+	if !secret.Confirmed {
+		return false, nil
+	}
+
+	if step, ok := totp.Validate(secret.Secret, code, time.Now()); ok {
+		err = app.models.MFA.UseStep(userID, step)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrMFACodeUsed):
+				return false, nil
+			default:
+				return false, err
+			}
+		}
+		return true, nil
+	}
+
+	err = app.models.MFA.UseRecoveryCode(userID, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			return false, nil
+		default:
+			return false, err
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/data/mock"
+	"github.com/IfedayoAwe/greenlight/internal/totp"
+)
+
+func TestTOTPEnrolment(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code := totp.Code(mock.MockTOTPSecret, totp.Step(time.Now()))
+
+	tests := []struct {
+		name     string
+		method   string
+		payload  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Enrol", http.MethodPost, "", http.StatusCreated, []byte("otpauth://totp/Greenlight:olalekanawe99@gmail.com?"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"EnrolAlreadyEnabled", http.MethodPost, "", http.StatusUnprocessableEntity, []byte("two-factor authentication is already enabled"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRN"},
+		{"EnrolUnAuthenticated", http.MethodPost, "", http.StatusUnauthorized, []byte("you must be authenticated to access this resource"), ""},
+		{"Confirm", http.MethodPut, `{"code": "` + code + `"}`, http.StatusOK, []byte("recovery_codes"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"},
+		{"ConfirmInvalidCode", http.MethodPut, `{"code": "000000x"}`, http.StatusUnauthorized, []byte("invalid two-factor authentication code"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"},
+		{"ConfirmNoCode", http.MethodPut, `{"code": ""}`, http.StatusUnprocessableEntity, []byte("\"code\": \"must be provided\""), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"},
+		{"ConfirmNotEnrolled", http.MethodPut, `{"code": "` + code + `"}`, http.StatusUnprocessableEntity, []byte("two-factor authentication enrolment has not been started"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"ConfirmAlreadyEnabled", http.MethodPut, `{"code": "` + code + `"}`, http.StatusUnprocessableEntity, []byte("two-factor authentication is already enabled"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRN"},
+		{"DisableInvalidCode", http.MethodDelete, `{"code": "ZZZZZ-ZZZZZ"}`, http.StatusUnauthorized, []byte("invalid two-factor authentication code"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRN"},
+		{"Disable", http.MethodDelete, `{"code": "` + code + `"}`, http.StatusOK, []byte("two-factor authentication successfully disabled"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRN"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+"/v1/users/mfa/totp", bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestMFALogin(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	code := totp.Code(mock.MockTOTPSecret, totp.Step(time.Now()))
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"PasswordReturnsMFAToken", "/v1/tokens/authentication", `{"email": "tunde@gmail.com", "password": "1234567890"}`, http.StatusCreated, []byte("mfa_token")},
+		{"TOTPCode", "/v1/tokens/mfa", `{"mfa_token": "MFA34GKUHNDUSJ3QRUT6IKWKRN", "code": "` + code + `"}`, http.StatusCreated, []byte("refresh_token")},
+		{"RecoveryCode", "/v1/tokens/mfa", `{"mfa_token": "MFA34GKUHNDUSJ3QRUT6IKWKRN", "code": "ABCDE-FGHIJ"}`, http.StatusCreated, []byte("authentication_token")},
+		{"InvalidCode", "/v1/tokens/mfa", `{"mfa_token": "MFA34GKUHNDUSJ3QRUT6IKWKRN", "code": "ZZZZZ-ZZZZZ"}`, http.StatusUnauthorized, []byte("invalid two-factor authentication code")},
+		{"InvalidMFAToken", "/v1/tokens/mfa", `{"mfa_token": "MFA34GKUHNDUSJ3QRUT6IKWKRX", "code": "` + code + `"}`, http.StatusUnprocessableEntity, []byte("invalid or expired mfa token")},
+		{"SuspendedUser", "/v1/tokens/mfa", `{"mfa_token": "SUS34GKUHNDUSJ3QRUT6IKWKRN", "code": "` + code + `"}`, http.StatusForbidden, []byte("your user account has been suspended")},
+		{"MissingCode", "/v1/tokens/mfa", `{"mfa_token": "MFA34GKUHNDUSJ3QRUT6IKWKRN"}`, http.StatusUnprocessableEntity, []byte("\"code\": \"must be provided\"")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRequireMFA(t *testing.T) {
+	app := newTestApplication(t)
+	app.models.Permissions = &mock.MockPermissionModel{RequiringMFA: data.Permissions{"movies:write"}}
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		payload  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"WriteWithoutMFA", http.MethodPost, "/v1/movies", `{"title": "Eve", "year": 2003, "runtime": "120 mins", "genres": ["drama"]}`, http.StatusForbidden, []byte("must have two-factor authentication enabled"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM"},
+		{"ReadWithoutMFA", http.MethodGet, "/v1/movies/1", "", http.StatusOK, []byte("movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM"},
+		{"RequireMFA", http.MethodPatch, "/v1/admin/permissions/movies:write", `{"requires_mfa": true}`, http.StatusOK, []byte("\"requires_mfa\": true"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"RequireMFAUnknownPermission", http.MethodPatch, "/v1/admin/permissions/movies:delete", `{"requires_mfa": true}`, http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"RequireMFAMissingValue", http.MethodPatch, "/v1/admin/permissions/movies:write", `{}`, http.StatusUnprocessableEntity, []byte("\"requires_mfa\": \"must be provided\""), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"RequireMFANotAdmin", http.MethodPatch, "/v1/admin/permissions/movies:write", `{"requires_mfa": true}`, http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/sha256"
 	"errors"
 	"expvar"
 	"fmt"
@@ -78,6 +79,12 @@ func (app *application) rateLimit(next http.Handler) http.Handler {
 func (app *application) authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Add("Vary", "Authorization")
+		w.Header().Add("Vary", "X-API-Key")
+
+		if key := r.Header.Get("X-API-Key"); key != "" {
+			app.authenticateAPIKey(w, r, key, next)
+			return
+		}
 
 		authorizationHeader := r.Header.Get("Authorization")
 		if authorizationHeader == "" {
@@ -87,6 +94,19 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		headerParts := strings.Split(authorizationHeader, " ")
+		if len(headerParts) == 2 && headerParts[0] == "ApiKey" {
+			app.authenticateAPIKey(w, r, headerParts[1], next)
+			return
+		}
+
+		// Basic credentials are only sent by OAuth clients to the /oauth
+		// endpoints, which authenticate the client themselves.
+		if len(headerParts) == 2 && headerParts[0] == "Basic" {
+			r = app.contextSetUser(r, data.AnonymousUser)
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		if len(headerParts) != 2 || headerParts[0] != "Bearer" {
 			app.invalidAuthenticationTokenResponse(w, r)
 			return
@@ -94,6 +114,11 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 
 		token := headerParts[1]
 
+		if app.jwtEnabled() && strings.Count(token, ".") == 2 {
+			app.authenticateJWT(w, r, token, next)
+			return
+		}
+
 		v := validator.New()
 
 		if data.ValidateTokenPlaintext(v, token); !v.Valid() {
@@ -102,6 +127,15 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 		}
 
 		user, err := app.models.Users.GetForToken(data.ScopeAuthentication, token)
+		if errors.Is(err, data.ErrRecordNotFound) {
+			// Bearer tokens issued to OAuth clients are looked up second, as
+			// they make up the minority of requests.
+			var oauthToken *data.Token
+			user, oauthToken, err = app.models.OAuth.GetForAccessToken(token)
+			if err == nil {
+				r = app.contextSetOAuthToken(r, oauthToken)
+			}
+		}
 		if err != nil {
 			switch {
 			case errors.Is(err, data.ErrRecordNotFound):
@@ -112,12 +146,43 @@ func (app *application) authenticate(next http.Handler) http.Handler {
 			return
 		}
 
+		tokenHash := sha256.Sum256([]byte(token))
+
 		r = app.contextSetUser(r, user)
+		r = app.contextSetToken(r, tokenHash[:])
 
 		next.ServeHTTP(w, r)
 	})
 }
 
+// authenticateAPIKey serves the request as the user the API key belongs to,
+// with the key stored in the request context so that requirePermission can
+// restrict the request to the permissions of the key.
+func (app *application) authenticateAPIKey(w http.ResponseWriter, r *http.Request, key string, next http.Handler) {
+	v := validator.New()
+
+	if data.ValidateAPIKeyPlaintext(v, key); !v.Valid() {
+		app.invalidAPIKeyResponse(w, r)
+		return
+	}
+
+	user, apiKey, err := app.models.APIKeys.GetForKey(key)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.invalidAPIKeyResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	r = app.contextSetUser(r, user)
+	r = app.contextSetAPIKey(r, apiKey)
+
+	next.ServeHTTP(w, r)
+}
+
 func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		user := app.contextGetUser(r)
@@ -129,7 +194,37 @@ func (app *application) requireAuthenticatedUser(next http.HandlerFunc) http.Han
 	})
 }
 
+// requireActivatedUser also turns away requests made with an API key or an
+// OAuth access token, as those may only reach the endpoints guarded by
+// requirePermission.
 func (app *application) requireActivatedUser(next http.HandlerFunc) http.HandlerFunc {
+	fn := func(w http.ResponseWriter, r *http.Request) {
+		if app.contextGetAPIKey(r) != nil {
+			app.apiKeyNotAllowedResponse(w, r)
+			return
+		}
+		if app.contextGetOAuthToken(r) != nil {
+			app.oauthTokenNotAllowedResponse(w, r)
+			return
+		}
+
+		r, err := app.loadJWTUser(r)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.invalidAuthenticationTokenResponse(w, r)
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	}
+	return app.requireActivatedAccount(fn)
+}
+
+func (app *application) requireActivatedAccount(next http.HandlerFunc) http.HandlerFunc {
 	fn := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 
 		user := app.contextGetUser(r)
@@ -137,6 +232,10 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 			app.inactiveAccountResponse(w, r)
 			return
 		}
+		if user.Suspended {
+			app.suspendedAccountResponse(w, r)
+			return
+		}
 		next.ServeHTTP(w, r)
 	})
 	return app.requireAuthenticatedUser(fn)
@@ -144,6 +243,20 @@ func (app *application) requireActivatedUser(next http.HandlerFunc) http.Handler
 
 func (app *application) requirePermission(code string, next http.HandlerFunc) http.HandlerFunc {
 	fn := func(w http.ResponseWriter, r *http.Request) {
+		// Signed tokens carry the permissions of the user they were issued to.
+		if claims := app.contextGetJWTClaims(r); claims != nil {
+			if !claims.Permissions.Include(code) {
+				app.notPermittedResponse(w, r)
+				return
+			}
+			if claims.MFARequired.Include(code) {
+				app.mfaRequiredResponse(w, r)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
 		user := app.contextGetUser(r)
 		permissions, err := app.models.Permissions.GetAllForUser(user.ID)
 		if err != nil {
@@ -154,9 +267,28 @@ func (app *application) requirePermission(code string, next http.HandlerFunc) ht
 			app.notPermittedResponse(w, r)
 			return
 		}
+		if key := app.contextGetAPIKey(r); key != nil && !key.Permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		if token := app.contextGetOAuthToken(r); token != nil && !token.Permissions.Include(code) {
+			app.notPermittedResponse(w, r)
+			return
+		}
+		if !user.MFAEnabled {
+			required, err := app.models.Permissions.GetRequiringMFA()
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			if required.Include(code) {
+				app.mfaRequiredResponse(w, r)
+				return
+			}
+		}
 		next.ServeHTTP(w, r)
 	}
-	return app.requireActivatedUser(fn)
+	return app.requireActivatedAccount(fn)
 }
 
 func (app *application) requireAdmin(next http.HandlerFunc) http.HandlerFunc {
@@ -182,7 +314,7 @@ func (app *application) enableCORS(next http.Handler) http.Handler {
 					// perflight request
 					if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
 						w.Header().Set("Access-Control-Allow-Methods", "OPTIONS, PUT, PATCH, DELETE")
-						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+						w.Header().Set("Access-Control-Allow-Headers", "Authorization, Content-Type, If-Match, If-None-Match, X-API-Key")
 						w.WriteHeader(http.StatusOK)
 						return
 					}
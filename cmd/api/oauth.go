@@ -0,0 +1,482 @@
+package main
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+)
+
+func (app *application) createOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Name         string   `json:"name"`
+		RedirectURIs []string `json:"redirect_uris"`
+		Scopes       []string `json:"scopes"`
+		Confidential bool     `json:"confidential"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	client, err := data.NewOAuthClient(user.ID, input.Name, input.RedirectURIs, input.Scopes, input.Confidential)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	if data.ValidateOAuthClient(v, client); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	err = app.models.OAuth.InsertClient(client)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	// The client secret must not end up in the audit log.
+	recorded := *client
+	recorded.Secret = ""
+	app.audit(r, "oauth_client.create", "oauth_client", client.ID, nil, recorded)
+
+	// The client secret is only ever included in this response.
+	err = app.writeJSON(w, http.StatusCreated, envelope{"client": client}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) listOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	clients, err := app.models.OAuth.GetAllClients()
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"clients": clients}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	err = app.models.OAuth.DeleteClient(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.audit(r, "oauth_client.delete", "oauth_client", id, nil, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "client successfully deleted"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// authorizationRequest holds the parameters of an authorization request, sent
+// in the query string to show the consent step and in the body to answer it.
+type authorizationRequest struct {
+	ResponseType        string `json:"response_type"`
+	ClientID            string `json:"client_id"`
+	RedirectURI         string `json:"redirect_uri"`
+	Scope               string `json:"scope"`
+	State               string `json:"state"`
+	CodeChallenge       string `json:"code_challenge"`
+	CodeChallengeMethod string `json:"code_challenge_method"`
+}
+
+// validateAuthorizationRequest checks an authorization request against the
+// client it names, returning the client and the permissions asked for. Only
+// the authorization code flow with an S256 PKCE challenge is supported.
+func (app *application) validateAuthorizationRequest(w http.ResponseWriter, r *http.Request, req *authorizationRequest) (*data.OAuthClient, data.Permissions, bool) {
+	v := validator.New()
+
+	v.Check(req.ResponseType == "code", "response_type", "must be code")
+	v.Check(req.ClientID != "", "client_id", "must be provided")
+	v.Check(len(req.CodeChallenge) >= 43 && len(req.CodeChallenge) <= 128, "code_challenge", "must be between 43 and 128 bytes long")
+	v.Check(req.CodeChallengeMethod == "S256", "code_challenge_method", "must be S256")
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil, nil, false
+	}
+
+	client, err := app.models.OAuth.GetClient(req.ClientID)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			v.AddError("client_id", "unknown client")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return nil, nil, false
+	}
+
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	v.Check(client.HasRedirectURI(req.RedirectURI), "redirect_uri", "must be one of the redirect uris registered for the client")
+
+	permissions := data.Permissions(strings.Fields(req.Scope))
+	if len(permissions) == 0 {
+		permissions = client.Scopes
+	}
+	for _, code := range permissions {
+		v.Check(client.Scopes.Include(code), "scope", "must only contain scopes the client is registered for")
+	}
+
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return nil, nil, false
+	}
+
+	return client, permissions, true
+}
+
+// showOAuthAuthorizationHandler validates an authorization request and
+// describes it, so that a consent screen can ask the user to approve it.
+func (app *application) showOAuthAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	qs := r.URL.Query()
+
+	req := authorizationRequest{
+		ResponseType:        qs.Get("response_type"),
+		ClientID:            qs.Get("client_id"),
+		RedirectURI:         qs.Get("redirect_uri"),
+		Scope:               qs.Get("scope"),
+		State:               qs.Get("state"),
+		CodeChallenge:       qs.Get("code_challenge"),
+		CodeChallengeMethod: qs.Get("code_challenge_method"),
+	}
+
+	client, permissions, ok := app.validateAuthorizationRequest(w, r, &req)
+	if !ok {
+		return
+	}
+
+	env := envelope{
+		"client":       envelope{"client_id": client.ClientID, "name": client.Name},
+		"scope":        permissions,
+		"redirect_uri": req.RedirectURI,
+		"state":        req.State,
+	}
+
+	err := app.writeJSON(w, http.StatusOK, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// createOAuthAuthorizationHandler records the answer of the user to an
+// authorization request, returning the redirect uri to send them back to the
+// client with, carrying either an authorization code or an access_denied error.
+func (app *application) createOAuthAuthorizationHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		authorizationRequest
+		Approve bool `json:"approve"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	client, permissions, ok := app.validateAuthorizationRequest(w, r, &input.authorizationRequest)
+	if !ok {
+		return
+	}
+
+	redirectURI, err := url.Parse(input.RedirectURI)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	params := redirectURI.Query()
+
+	if input.Approve {
+		code := &data.OAuthCode{
+			UserID:        app.contextGetUser(r).ID,
+			ClientID:      client.ID,
+			Permissions:   permissions,
+			RedirectURI:   input.RedirectURI,
+			CodeChallenge: input.CodeChallenge,
+		}
+
+		token, err := app.models.OAuth.NewCode(code, app.config.oauth.codeTTL, r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		params.Set("code", token.Plaintext)
+	} else {
+		params.Set("error", "access_denied")
+	}
+
+	if input.State != "" {
+		params.Set("state", input.State)
+	}
+	redirectURI.RawQuery = params.Encode()
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"redirect_uri": redirectURI.String()}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// authenticateOAuthClient reads the form of a request to one of the client
+// facing OAuth endpoints and authenticates the client with HTTP Basic
+// credentials or client_id and client_secret form values. Public clients only
+// identify themselves with their client_id.
+func (app *application) authenticateOAuthClient(w http.ResponseWriter, r *http.Request) (*data.OAuthClient, bool) {
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return nil, false
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, 1_048_576)
+
+	err := r.ParseForm()
+	if err != nil {
+		app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_request", "the body must be a valid form")
+		return nil, false
+	}
+
+	clientID, secret, ok := r.BasicAuth()
+	if !ok {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	if clientID == "" {
+		app.oauthErrorResponse(w, r, http.StatusUnauthorized, "invalid_client", "client authentication is required")
+		return nil, false
+	}
+
+	client, err := app.models.OAuth.GetClient(clientID)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return nil, false
+	}
+
+	if err != nil || (client.Confidential && !client.SecretMatches(secret)) || (!client.Confidential && secret != "") {
+		app.recordFailedAttempt(r, ipAttempt(r))
+		app.oauthErrorResponse(w, r, http.StatusUnauthorized, "invalid_client", "invalid client credentials")
+		return nil, false
+	}
+
+	return client, true
+}
+
+// verifyCodeChallenge reports whether verifier matches an S256 PKCE challenge.
+func verifyCodeChallenge(verifier, challenge string) bool {
+	sum := sha256.Sum256([]byte(verifier))
+	encoded := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(encoded), []byte(challenge)) == 1
+}
+
+func (app *application) oauthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	var (
+		access, refresh *data.Token
+		err             error
+	)
+
+	switch r.PostForm.Get("grant_type") {
+	case "authorization_code":
+		verifier := r.PostForm.Get("code_verifier")
+		if r.PostForm.Get("code") == "" || len(verifier) < 43 || len(verifier) > 128 {
+			app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_request", "code and a code_verifier between 43 and 128 bytes long must be provided")
+			return
+		}
+
+		code, err := app.models.OAuth.ExchangeCode(r.PostForm.Get("code"), client.ID)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.recordFailedAttempt(r, ipAttempt(r))
+				app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired authorization code")
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+		if code.RedirectURI != r.PostForm.Get("redirect_uri") || !verifyCodeChallenge(verifier, code.CodeChallenge) {
+			app.recordFailedAttempt(r, ipAttempt(r))
+			app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "the redirect_uri or code_verifier does not match the authorization request")
+			return
+		}
+
+		access, refresh, err = app.models.OAuth.NewTokens(code.UserID, client.ID, code.Permissions, app.config.oauth.accessTTL, app.config.tokens.refreshTTL, r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") == "" {
+			app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_request", "refresh_token must be provided")
+			return
+		}
+
+		access, refresh, err = app.models.OAuth.Rotate(r.PostForm.Get("refresh_token"), client.ID, app.config.oauth.accessTTL, app.config.tokens.refreshTTL, r)
+		if err != nil {
+			switch {
+			case errors.Is(err, data.ErrTokenReused):
+				app.logSecurityEvent(r, "oauth_refresh_token_reuse", map[string]string{"client_id": client.ClientID})
+				app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired refresh token")
+			case errors.Is(err, data.ErrRecordNotFound):
+				app.recordFailedAttempt(r, ipAttempt(r))
+				app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_grant", "invalid or expired refresh token")
+			default:
+				app.serverErrorResponse(w, r, err)
+			}
+			return
+		}
+
+	case "client_credentials":
+		if !client.Confidential {
+			app.oauthErrorResponse(w, r, http.StatusBadRequest, "unauthorized_client", "only confidential clients may use the client_credentials grant")
+			return
+		}
+
+		permissions := data.Permissions(strings.Fields(r.PostForm.Get("scope")))
+		if len(permissions) == 0 {
+			permissions = client.Scopes
+		}
+		for _, code := range permissions {
+			if !client.Scopes.Include(code) {
+				app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_scope", "scope must only contain scopes the client is registered for")
+				return
+			}
+		}
+
+		// Client credentials act as the user who registered the client and
+		// come without a refresh token, as the client can always ask again.
+		access, _, err = app.models.OAuth.NewTokens(client.UserID, client.ID, permissions, app.config.oauth.accessTTL, 0, r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+	default:
+		app.oauthErrorResponse(w, r, http.StatusBadRequest, "unsupported_grant_type", "grant_type must be authorization_code, refresh_token or client_credentials")
+		return
+	}
+
+	env := envelope{
+		"access_token": access.Plaintext,
+		"token_type":   "Bearer",
+		"expires_in":   int(app.config.oauth.accessTTL.Seconds()),
+		"scope":        strings.Join(access.Permissions, " "),
+	}
+	if refresh != nil {
+		env["refresh_token"] = refresh.Plaintext
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+	headers.Set("Pragma", "no-cache")
+
+	err = app.writeJSON(w, http.StatusOK, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// oauthIntrospectHandler implements RFC 7662 token introspection for the
+// tokens issued to the calling client.
+func (app *application) oauthIntrospectHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	env := envelope{"active": false}
+
+	user, token, err := app.models.OAuth.Introspect(r.PostForm.Get("token"), client.ID)
+	switch {
+	case err == nil:
+		tokenType := "access_token"
+		if token.Scope == data.ScopeOAuthRefresh {
+			tokenType = "refresh_token"
+		}
+
+		env = envelope{
+			"active":     true,
+			"scope":      strings.Join(token.Permissions, " "),
+			"client_id":  client.ClientID,
+			"username":   user.Email,
+			"sub":        strconv.FormatInt(user.ID, 10),
+			"exp":        token.Expiry.Unix(),
+			"token_type": tokenType,
+		}
+	case !errors.Is(err, data.ErrRecordNotFound):
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	headers := make(http.Header)
+	headers.Set("Cache-Control", "no-store")
+
+	err = app.writeJSON(w, http.StatusOK, env, headers)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// oauthRevokeHandler implements RFC 7009 token revocation. Revoking either
+// token of a grant revokes the other as well.
+func (app *application) oauthRevokeHandler(w http.ResponseWriter, r *http.Request) {
+	client, ok := app.authenticateOAuthClient(w, r)
+	if !ok {
+		return
+	}
+
+	if r.PostForm.Get("token") == "" {
+		app.oauthErrorResponse(w, r, http.StatusBadRequest, "invalid_request", "token must be provided")
+		return
+	}
+
+	err := app.models.OAuth.Revoke(r.PostForm.Get("token"), client.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestPeople(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	writer := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"
+	reader := "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		token    string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"List", http.MethodGet, "/v1/people?name=nolan", reader, "", http.StatusOK, []byte("\"name\": \"Christopher Nolan\"")},
+		{"InvalidSort", http.MethodGet, "/v1/people?sort=bio", reader, "", http.StatusUnprocessableEntity, []byte("invalid sort value")},
+		{"Show", http.MethodGet, "/v1/people/1", reader, "", http.StatusOK, []byte("\"birth_date\": \"1970-07-30\"")},
+		{"ShowNotFound", http.MethodGet, "/v1/people/9", reader, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"Create", http.MethodPost, "/v1/people", writer, `{"name": "New Person", "birth_date": "1980-01-02"}`, http.StatusCreated, []byte("\"birth_date\": \"1980-01-02\"")},
+		{"CreateNoName", http.MethodPost, "/v1/people", writer, `{"bio": "Test"}`, http.StatusUnprocessableEntity, []byte("\"name\": \"must be provided\"")},
+		{"CreateInvalidDate", http.MethodPost, "/v1/people", writer, `{"name": "New Person", "birth_date": "02/01/1980"}`, http.StatusBadRequest, []byte("invalid date format")},
+		{"CreateNotPermitted", http.MethodPost, "/v1/people", reader, `{"name": "New Person"}`, http.StatusForbidden, []byte("your user account is not permitted to access this resource")},
+		{"Update", http.MethodPatch, "/v1/people/1", writer, `{"bio": "Updated Bio"}`, http.StatusOK, []byte("\"bio\": \"Updated Bio\"")},
+		{"Delete", http.MethodDelete, "/v1/people/2", writer, "", http.StatusOK, []byte("person successfully deleted")},
+		{"Filmography", http.MethodGet, "/v1/people/1/filmography", reader, "", http.StatusOK, []byte("\"title\": \"Test Movie\"")},
+		{"Credits", http.MethodGet, "/v1/movies/1/credits", reader, "", http.StatusOK, []byte("\"character\": \"Test Character\"")},
+		{"CreditsNotFound", http.MethodGet, "/v1/movies/2/credits", reader, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+		{"AddCredit", http.MethodPost, "/v1/movies/1/credits", writer, `{"person_id": 2, "role": "writer"}`, http.StatusCreated, []byte("\"role\": \"writer\"")},
+		{"AddDuplicateCredit", http.MethodPost, "/v1/movies/1/credits", writer, `{"person_id": 1, "role": "director"}`, http.StatusConflict, []byte("this person has already been credited with this role on the movie")},
+		{"AddCreditUnknownPerson", http.MethodPost, "/v1/movies/1/credits", writer, `{"person_id": 9, "role": "actor"}`, http.StatusUnprocessableEntity, []byte("must be the id of an existing person")},
+		{"AddCreditInvalidRole", http.MethodPost, "/v1/movies/1/credits", writer, `{"person_id": 2, "role": "producer"}`, http.StatusUnprocessableEntity, []byte("must be one of director, writer or actor")},
+		{"AddCreditCharacter", http.MethodPost, "/v1/movies/1/credits", writer, `{"person_id": 1, "role": "director", "character": "Himself"}`, http.StatusUnprocessableEntity, []byte("must only be provided for actors")},
+		{"RemoveCredit", http.MethodDelete, "/v1/movies/1/credits/2", writer, "", http.StatusOK, []byte("credit successfully deleted")},
+		{"RemoveCreditNotFound", http.MethodDelete, "/v1/movies/1/credits/9", writer, "", http.StatusNotFound, []byte("the requested resource could not be found")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, strings.NewReader(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
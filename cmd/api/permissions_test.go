@@ -63,3 +63,57 @@ func TestAddMovieWritePermission(t *testing.T) {
 	}
 
 }
+
+func TestUserRolesAndPermissions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		urlPath  string
+		payload  string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Show", http.MethodGet, "/v1/admin/users/3/permissions", "", http.StatusOK, []byte("\"roles\": [\n\t\t\"viewer\"\n\t]"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"ShowNotFound", http.MethodGet, "/v1/admin/users/9/permissions", "", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"ShowNotAdmin", http.MethodGet, "/v1/admin/users/3/permissions", "", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"},
+		{"AssignRole", http.MethodPost, "/v1/admin/users/3/roles", `{"roles": ["contributor"]}`, http.StatusOK, []byte("permissions"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"AssignUnknownRole", http.MethodPost, "/v1/admin/users/3/roles", `{"roles": ["owner"]}`, http.StatusUnprocessableEntity, []byte("must only contain existing roles"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"AssignNoRoles", http.MethodPost, "/v1/admin/users/3/roles", `{"roles": []}`, http.StatusUnprocessableEntity, []byte("must contain at least 1 role"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"UnassignRole", http.MethodDelete, "/v1/admin/users/4/roles/contributor", "", http.StatusOK, []byte("permissions"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"UnassignMissingRole", http.MethodDelete, "/v1/admin/users/3/roles/contributor", "", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"GrantPermission", http.MethodPost, "/v1/admin/users/3/permissions", `{"permissions": ["movies:write"]}`, http.StatusOK, []byte("permissions"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"GrantDuplicatePermission", http.MethodPost, "/v1/admin/users/4/permissions", `{"permissions": ["movies:write"]}`, http.StatusUnprocessableEntity, []byte("This user already has this permission"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"RevokePermission", http.MethodDelete, "/v1/admin/users/4/permissions/movies:write", "", http.StatusOK, []byte("permissions"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"RevokeMissingPermission", http.MethodDelete, "/v1/admin/users/3/permissions/movies:write", "", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+func TestCreateReview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		rating   int
+		body     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"Created", 9, "Great movie", http.StatusCreated, []byte("Great movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/reviews"},
+		{"Duplicate", 9, "Great movie", http.StatusUnprocessableEntity, []byte("you have already reviewed this movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/1/reviews"},
+		{"NoRating", 0, "Great movie", http.StatusUnprocessableEntity, []byte("\"rating\": \"must be provided\""), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/reviews"},
+		{"RatingTooHigh", 11, "", http.StatusUnprocessableEntity, []byte("\"rating\": \"must be between 1 and 10\""), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/reviews"},
+		{"MovieNotFound", 5, "", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/2/reviews"},
+		{"UnAuthenticated", 5, "", http.StatusUnauthorized, []byte("you must be authenticated to access this resource"), "", "/v1/movies/1/reviews"},
+		{"UnActivated", 5, "", http.StatusForbidden, []byte("your user account must be activated to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRJ", "/v1/movies/1/reviews"},
+		{"ReadOnlyAPIKey", 5, "", http.StatusForbidden, []byte("this resource cannot be accessed with an api key"), "ApiKey AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA", "/v1/movies/1/reviews"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			review := struct {
+				Rating int
+				Body   string
+			}{
+				Rating: tt.rating,
+				Body:   tt.body,
+			}
+
+			payload, err := json.Marshal(review)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestListReviews(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"AllReviews", http.StatusOK, []byte("Test Review"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/reviews"},
+		{"SortRatingDesc", http.StatusOK, []byte("Test Review"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/reviews?sort=-rating&page=1&page_size=5"},
+		{"FailedValidation", http.StatusUnprocessableEntity, []byte("invalid sort value"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/reviews?sort=foo"},
+		{"MovieNotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/2/reviews"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUpdateReview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	review1 := struct{ Rating int }{6}
+	review2 := struct{ Body string }{"Changed my mind"}
+	review3 := struct{ Rating int }{20}
+
+	tests := []struct {
+		name     string
+		review   interface{}
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"Rating", review1, http.StatusOK, []byte("\"rating\": 6"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/1"},
+		{"Body", review2, http.StatusOK, []byte("Changed my mind"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/1"},
+		{"FailedValidation", review3, http.StatusUnprocessableEntity, []byte("must be between 1 and 10"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/1"},
+		{"NotExist", review1, http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/5"},
+		{"NotPermitted", review1, http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/reviews/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := json.Marshal(tt.review)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPatch, ts.URL+tt.urlPath, bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDeleteReview(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"Deleted", http.StatusOK, []byte("review successfully deleted"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/1"},
+		{"NotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/2"},
+		{"NotFoundFoo", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/reviews/foo"},
+		{"NotPermitted", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM", "/v1/reviews/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodDelete, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestListRevisions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"AllRevisions", http.StatusOK, []byte("Original Movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions"},
+		{"SortVersion", http.StatusOK, []byte("Original Movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions?sort=version"},
+		{"FailedValidation", http.StatusUnprocessableEntity, []byte("invalid sort value"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions?sort=title"},
+		{"MovieNotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/2/revisions"},
+		{"ShowRevision", http.StatusOK, []byte("\"action\": \"create\""), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions/1"},
+		{"RevisionNotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions/9"},
+		{"ShowRevisionDeletedMovie", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/3/revisions/1"},
+		{"RevisionFoo", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions/foo"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRevertMovie(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"Reverted", http.StatusOK, []byte("Original Movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/1/revisions/1/revert"},
+		{"RevisionNotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/1/revisions/9/revert"},
+		{"MovieNotFound", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/2/revisions/1/revert"},
+		{"NotPermitted", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM", "/v1/movies/1/revisions/1/revert"},
+		{"NoWritePermission", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/1/revisions/1/revert"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -12,24 +12,106 @@ func (app *application) routes() http.Handler {
 	router.NotFound = http.HandlerFunc(app.notFoundResponse)
 	router.MethodNotAllowed = http.HandlerFunc(app.methodNotAllowedResponse)
 	router.HandlerFunc(http.MethodGet, "/v1/healthcheck", app.healthcheckHandler)
+	router.HandlerFunc(http.MethodGet, "/.well-known/jwks.json", app.jwksHandler)
 	router.HandlerFunc(http.MethodGet, "/v1/movies", app.requirePermission("movies:read", app.listMoviesHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/movies", app.requirePermission("movies:write", app.createMovieHandler))
-	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.requirePermission("movies:read", app.showMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id", app.staticOrID(map[string]http.HandlerFunc{
+		"trash":   app.requireActivatedUser(app.listTrashHandler),
+		"suggest": app.requirePermission("movies:read", app.suggestMoviesHandler),
+	}, app.requirePermission("movies:read", app.showMovieHandler)))
 	router.HandlerFunc(http.MethodPatch, "/v1/movies/:id", app.requirePermission("movies:write", app.updateMovieHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id", app.requirePermission("movies:write", app.deleteMovieHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/restore", app.requirePermission("movies:write", app.restoreMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions", app.requirePermission("movies:read", app.listRevisionsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/revisions/:version", app.requirePermission("movies:read", app.showRevisionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/revisions/:version/revert", app.requirePermission("movies:write", app.revertMovieHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/reviews", app.requirePermission("movies:read", app.listReviewsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/reviews", app.requireActivatedUser(app.createReviewHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/reviews/:id", app.requireActivatedUser(app.updateReviewHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/reviews/:id", app.requireActivatedUser(app.deleteReviewHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/movies/:id/credits", app.requirePermission("movies:read", app.listMovieCreditsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/movies/:id/credits", app.requirePermission("movies:write", app.createMovieCreditHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/movies/:id/credits/:credit_id", app.requirePermission("movies:write", app.deleteMovieCreditHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists", app.requirePermission("movies:read", app.listListsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists", app.requireActivatedUser(app.createListHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/lists/:id", app.requirePermission("movies:read", app.showListHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id", app.requireActivatedUser(app.updateListHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id", app.requireActivatedUser(app.deleteListHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/lists/:id/movies", app.requireActivatedUser(app.addListEntryHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/lists/:id/movies/:movie_id", app.requireActivatedUser(app.updateListEntryHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/lists/:id/movies/:movie_id", app.requireActivatedUser(app.removeListEntryHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/genres", app.requirePermission("movies:read", app.listGenresHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people", app.requirePermission("movies:read", app.listPeopleHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/people", app.requirePermission("movies:write", app.createPersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id", app.requirePermission("movies:read", app.showPersonHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/people/:id", app.requirePermission("movies:write", app.updatePersonHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/people/:id", app.requirePermission("movies:write", app.deletePersonHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/people/:id/filmography", app.requirePermission("movies:read", app.showFilmographyHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/users", app.registerUserHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/activated", app.activateUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/unlocked", app.unlockUserHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/email", app.confirmEmailChangeHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/users/email/revert", app.revertEmailChangeHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/authentication", app.createAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/refresh", app.refreshAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/mfa", app.createMFAAuthenticationTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/v1/tokens/magic-link", app.createMagicLinkTokenHandler)
+	router.HandlerFunc(http.MethodPut, "/v1/tokens/magic-link", app.exchangeMagicLinkTokenHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/change-password", app.requireActivatedUser(app.changePasswordHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/password", app.createPasswordResetTokenHandler)
 	router.HandlerFunc(http.MethodPut, "/v1/users/password", app.resetUserPasswordHandler)
 	router.HandlerFunc(http.MethodPost, "/v1/tokens/activation", app.createActivationTokenHandler)
 	router.HandlerFunc(http.MethodPatch, "/v1/users/update-details", app.requireActivatedUser(app.updateUserDetailsHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/users/logout", app.requireActivatedUser(app.userLogoutHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/mfa/totp", app.requireActivatedUser(app.enrolTOTPHandler))
+	router.HandlerFunc(http.MethodPut, "/v1/users/mfa/totp", app.requireActivatedUser(app.confirmTOTPHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/mfa/totp", app.requireActivatedUser(app.disableTOTPHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/sessions", app.requireActivatedUser(app.listSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/sessions", app.requireActivatedUser(app.deleteOtherSessionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/sessions/:id", app.requireActivatedUser(app.deleteSessionHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/api-keys", app.requireActivatedUser(app.listAPIKeysHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/api-keys", app.requireActivatedUser(app.createAPIKeyHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/api-keys/:id", app.requireActivatedUser(app.deleteAPIKeyHandler))
 	router.HandlerFunc(http.MethodPut, "/v1/users/profile", app.requireActivatedUser(app.userProfileHandler))
 	router.HandlerFunc(http.MethodGet, "/v1/user/profile", app.requireActivatedUser(app.getUserProfileHandler))
 	router.HandlerFunc(http.MethodDelete, "/v1/users/delete", app.requireActivatedUser(app.deleteUserAccountHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/export", app.requireActivatedUser(app.createExportHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/export", app.requireActivatedUser(app.downloadExportHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/watchlist", app.requireActivatedUser(app.listWatchlistHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/watchlist", app.requireActivatedUser(app.addToWatchlistHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/watchlist/:id", app.requireActivatedUser(app.removeFromWatchlistHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/users/me/watched", app.requireActivatedUser(app.listWatchedHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/users/me/watched", app.requireActivatedUser(app.addWatchedHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/users/me/watched/:id", app.requireActivatedUser(app.deleteWatchedHandler))
 	router.HandlerFunc(http.MethodPost, "/v1/users/movie-permission", app.requireAdmin(app.addMovieWritePermissionForUser))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/roles", app.requireAdmin(app.listRolesHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/roles", app.requireAdmin(app.createRoleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/roles/:id", app.requireAdmin(app.updateRoleHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/permissions/:code", app.requireAdmin(app.updatePermissionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/genres", app.requireAdmin(app.createGenreHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/genres/:id", app.requireAdmin(app.updateGenreHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/genres/:id/merge", app.requireAdmin(app.mergeGenreHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users", app.requireAdmin(app.listUsersHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id", app.requireAdmin(app.showUserHandler))
+	router.HandlerFunc(http.MethodPatch, "/v1/admin/users/:id", app.requireAdmin(app.updateUserStatusHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id", app.requireAdmin(app.adminDeleteUserHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/password-reset", app.requireAdmin(app.forcePasswordResetHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/tokens", app.requireAdmin(app.revokeUserCredentialsHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/users/:id/permissions", app.requireAdmin(app.showUserPermissionsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/permissions", app.requireAdmin(app.addUserPermissionsHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/permissions/:code", app.requireAdmin(app.removeUserPermissionHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/unlock", app.requireAdmin(app.adminUnlockUserHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/users/:id/roles", app.requireAdmin(app.addUserRolesHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/users/:id/roles/:role", app.requireAdmin(app.removeUserRoleHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/admin/oauth/clients", app.requireAdmin(app.listOAuthClientsHandler))
+	router.HandlerFunc(http.MethodPost, "/v1/admin/oauth/clients", app.requireAdmin(app.createOAuthClientHandler))
+	router.HandlerFunc(http.MethodDelete, "/v1/admin/oauth/clients/:id", app.requireAdmin(app.deleteOAuthClientHandler))
+	router.HandlerFunc(http.MethodGet, "/v1/audit", app.requireAdmin(app.listAuditEventsHandler))
+	router.HandlerFunc(http.MethodGet, "/oauth/authorize", app.requireActivatedUser(app.showOAuthAuthorizationHandler))
+	router.HandlerFunc(http.MethodPost, "/oauth/authorize", app.requireActivatedUser(app.createOAuthAuthorizationHandler))
+	router.HandlerFunc(http.MethodPost, "/oauth/token", app.oauthTokenHandler)
+	router.HandlerFunc(http.MethodPost, "/oauth/introspect", app.oauthIntrospectHandler)
+	router.HandlerFunc(http.MethodPost, "/oauth/revoke", app.oauthRevokeHandler)
 
 	handler := http.StripPrefix("/profile", http.HandlerFunc(app.showProfilePictureHandler))
 	router.HandlerFunc(http.MethodGet, "/profile/:filepath", app.requireActivatedUser(handler.ServeHTTP))
@@ -38,3 +120,16 @@ func (app *application) routes() http.Handler {
 	router.Handler(http.MethodGet, "/debug/vars", expvar.Handler())
 	return app.metrics(app.recoverPanic(app.enableCORS(app.rateLimit(app.authenticate(router)))))
 }
+
+// staticOrID lets static path segments share a position with the :id
+// wildcard, which httprouter does not allow to be registered side by side.
+func (app *application) staticOrID(static map[string]http.HandlerFunc, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		segment := httprouter.ParamsFromContext(r.Context()).ByName("id")
+		if handler, ok := static[segment]; ok {
+			handler(w, r)
+			return
+		}
+		next(w, r)
+	}
+}
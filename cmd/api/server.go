@@ -42,6 +42,8 @@ func (app *application) serve() error {
 			"addr": srv.Addr,
 		})
 
+		close(app.quit)
+
 		app.wg.Wait()
 		shutdownError <- nil
 	}()
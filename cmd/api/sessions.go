@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+func (app *application) listSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	sessions, err := app.models.Tokens.GetSessionsForUser(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	current := app.contextGetToken(r)
+	for _, session := range sessions {
+		session.Current = bytes.Equal(session.Hash, current)
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"sessions": sessions}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteSessionHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := app.readIDParam(r)
+	if err != nil {
+		app.notFoundResponse(w, r)
+		return
+	}
+
+	user := app.contextGetUser(r)
+
+	err = app.revokeSessionJWTs(user.ID, func(session *data.Session) bool {
+		return session.ID == id
+	})
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.models.Tokens.DeleteSession(user.ID, id)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.notFoundResponse(w, r)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "session successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) deleteOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := app.contextGetUser(r)
+
+	err := app.deleteOtherSessions(user.ID, app.contextGetToken(r))
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"message": "all other sessions successfully revoked"}, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+// deleteOtherSessions revokes every session of the user except the current
+// one, along with their signed tokens.
+func (app *application) deleteOtherSessions(userID int64, current []byte) error {
+	err := app.revokeSessionJWTs(userID, func(session *data.Session) bool {
+		return !bytes.Equal(session.Hash, current)
+	})
+	if err != nil {
+		return err
+	}
+
+	return app.models.Tokens.DeleteOtherSessions(userID, current)
+}
+
+// revokeAllCredentials signs the user out everywhere, revoking their sessions,
+// signed tokens, API keys and the tokens issued to OAuth clients on their
+// behalf.
+func (app *application) revokeAllCredentials(userID int64) error {
+	err := app.revokeAllSessions(userID)
+	if err != nil {
+		return err
+	}
+
+	return app.models.APIKeys.DeleteAllForUser(userID)
+}
+
+// revokeAllSessions revokes the sessions and signed tokens of the user and
+// the tokens issued to OAuth clients on their behalf, but not their API keys.
+func (app *application) revokeAllSessions(userID int64) error {
+	err := app.revokeUserJWTs(userID)
+	if err != nil {
+		return err
+	}
+
+	for _, scope := range []string{data.ScopeAuthentication, data.ScopeRefresh, data.ScopeOAuthAccess, data.ScopeOAuthRefresh} {
+		err = app.models.Tokens.DeleteAllForUser(scope, userID, nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestListSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+	}{
+		{"Current", http.StatusOK, []byte("\"current\": true"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"OtherDevice", http.StatusOK, []byte("\"device_name\": \"Ola's iPhone\""), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"NoSessions", http.StatusOK, []byte("\"sessions\": []"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"},
+		{"NotActivated", http.StatusForbidden, []byte("your user account must be activated to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRJ"},
+		{"UnAuthenticated", http.StatusUnauthorized, []byte("you must be authenticated to access this resource"), ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+"/v1/users/sessions", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestDeleteSessions(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		urlPath  string
+		token    string
+	}{
+		{"Revoked", http.StatusOK, []byte("session successfully revoked"), "/v1/users/sessions/2", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"OtherUsersSession", http.StatusNotFound, []byte("the requested resource could not be found"), "/v1/users/sessions/2", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL"},
+		{"InvalidID", http.StatusNotFound, []byte("the requested resource could not be found"), "/v1/users/sessions/abc", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"EverywhereElse", http.StatusOK, []byte("all other sessions successfully revoked"), "/v1/users/sessions", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"Logout", http.StatusOK, []byte("user sucessfully logged out"), "/v1/users/logout", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI"},
+		{"UnAuthenticated", http.StatusUnauthorized, []byte("you must be authenticated to access this resource"), "/v1/users/sessions", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodDelete, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
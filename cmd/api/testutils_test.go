@@ -8,6 +8,7 @@ import (
 	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/data/mock"
 	"github.com/IfedayoAwe/greenlight/internal/jsonlog"
@@ -29,12 +30,29 @@ func newTestApplication(t *testing.T) *application {
 	testCfg.metrics.enabled = false
 	testCfg.profile.enabled = false
 	testCfg.cors.trustedOrigins = []string{"*"}
+	testCfg.trash.retention = 30 * 24 * time.Hour
+	testCfg.trash.sweepInterval = time.Hour
+	testCfg.tokens.accessTTL = 15 * time.Minute
+	testCfg.tokens.refreshTTL = 30 * 24 * time.Hour
+	testCfg.tokens.format = tokenFormatOpaque
+	testCfg.jwt.revocationSync = 30 * time.Second
+	testCfg.oauth.accessTTL = time.Hour
+	testCfg.oauth.codeTTL = 10 * time.Minute
+	testCfg.lockout.threshold = 10
+	testCfg.lockout.ipThreshold = 100
+	testCfg.lockout.delayAfter = 3
+	testCfg.lockout.duration = 15 * time.Minute
+	testCfg.lockout.enabled = true
+	testCfg.export.dir = t.TempDir()
+	testCfg.export.ttl = 24 * time.Hour
+	testCfg.cursor.secret = "e1a3f4c5d2b6a7980f1e2d3c4b5a6978"
 
 	return &application{
-		config: testCfg,
-		logger: jsonlog.New(os.Stderr, jsonlog.LevelInfo),
-		models: mock.NewMockModels(),
-		mailer: mailer.New(testCfg.smtp.host, testCfg.smtp.port, testCfg.smtp.username, testCfg.smtp.password, testCfg.smtp.sender, testCfg.smtp.enabled),
+		config:      testCfg,
+		logger:      jsonlog.New(os.Stderr, jsonlog.LevelInfo),
+		models:      mock.NewMockModels(),
+		mailer:      mailer.New(testCfg.smtp.host, testCfg.smtp.port, testCfg.smtp.username, testCfg.smtp.password, testCfg.smtp.sender, testCfg.smtp.enabled),
+		revocations: newRevocationList(),
 	}
 }
 
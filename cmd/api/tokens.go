@@ -11,8 +11,9 @@ import (
 
 func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
 	var input struct {
-		Email    string `json:"email"`
-		Password string `json:"password"`
+		Email      string `json:"email"`
+		Password   string `json:"password"`
+		DeviceName string `json:"device_name"`
 	}
 
 	err := app.readJSON(w, r, &input)
@@ -25,15 +26,21 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 
 	data.ValidateEmail(v, input.Email)
 	data.ValidatePasswordPlaintext(v, input.Password)
+	data.ValidateDeviceName(v, input.DeviceName)
 	if !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	if !app.checkAttempts(w, r, emailAttempt(input.Email), ipAttempt(r)) {
+		return
+	}
+
 	user, err := app.models.Users.GetByEmail(input.Email)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, emailAttempt(input.Email), ipAttempt(r))
 			app.invalidCredentialsResponse(w, r)
 		default:
 			app.serverErrorResponse(w, r, err)
@@ -53,17 +60,253 @@ func (app *application) createAuthenticationTokenHandler(w http.ResponseWriter,
 	}
 
 	if !match {
+		app.recordFailedAttempt(r, emailAttempt(input.Email), ipAttempt(r))
 		app.invalidCredentialsResponse(w, r)
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication, r)
+	app.resetAttempts(r, emailAttempt(input.Email))
+
+	app.issueAuthenticationTokens(w, r, user, input.DeviceName, "password")
+}
+
+// issueAuthenticationTokens signs in a user who has proven who they are,
+// writing an authentication and refresh token to the response. Users with
+// two-factor authentication get a short-lived token to exchange together with
+// a code at POST /v1/tokens/mfa instead. method is the way the user proved
+// who they are, which is recorded in the audit log.
+func (app *application) issueAuthenticationTokens(w http.ResponseWriter, r *http.Request, user *data.User, deviceName, method string) {
+	if user.Suspended {
+		app.suspendedAccountResponse(w, r)
+		return
+	}
+
+	if user.MFAEnabled {
+		token, err := app.models.Tokens.New(user.ID, 5*time.Minute, data.ScopeMFA, r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		err = app.writeJSON(w, http.StatusCreated, envelope{"mfa_token": token}, nil)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	app.createSession(w, r, user, deviceName, method)
+}
+
+// createSession writes a new authentication and refresh token pair for user to
+// the response. Every way of signing in ends here, so the suspension check is
+// made even when an earlier step has already passed it.
+func (app *application) createSession(w http.ResponseWriter, r *http.Request, user *data.User, deviceName, method string) {
+	if user.Suspended {
+		app.suspendedAccountResponse(w, r)
+		return
+	}
+
+	token, refreshToken, err := app.models.Tokens.NewPair(user.ID, deviceName, app.config.tokens.accessTTL, app.config.tokens.refreshTTL, r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.signAccessToken(token)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.auditAs(r, &user.ID, "user.login", "user", user.ID, nil, envelope{"method": method, "device_name": deviceName})
+
+	env := envelope{"authentication_token": token, "refresh_token": refreshToken}
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) createMagicLinkTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		Email string `json:"email"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateEmail(v, input.Email); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	// Every request counts against the email address, known or not, so that
+	// throttling does not give away which addresses have an account either.
+	if !app.checkAttempts(w, r, magicLinkAttempt(input.Email)) {
+		return
+	}
+	app.recordFailedAttempt(r, magicLinkAttempt(input.Email))
+
+	// The response is the same whether or not the email address belongs to
+	// an activated account, so that it cannot be used to discover accounts.
+	env := envelope{"message": "if an activated account with this email address exists, an email will be sent to it containing a sign in token"}
+
+	user, err := app.models.Users.GetByEmail(input.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			err = app.writeJSON(w, http.StatusAccepted, env, nil)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+			}
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	if user.Activated {
+		token, err := app.models.Tokens.New(user.ID, 15*time.Minute, data.ScopeMagicLogin, r)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		app.background(func() {
+			data := map[string]interface{}{
+				"magicLoginToken": token.Plaintext,
+			}
+
+			err := app.mailer.Send(user.Email, "token_magic_login.html", data)
+			if err != nil {
+				app.logger.PrintError(err, nil)
+			}
+		})
+	}
+
+	err = app.writeJSON(w, http.StatusAccepted, env, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+	}
+}
+
+func (app *application) exchangeMagicLinkTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"token"`
+		DeviceName     string `json:"device_name"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+
+	data.ValidateTokenPlaintext(v, input.TokenPlaintext)
+	data.ValidateDeviceName(v, input.DeviceName)
+	if !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
+	user, err := app.models.Users.GetForToken(data.ScopeMagicLogin, input.TokenPlaintext)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
+			v.AddError("token", "invalid or expired sign in token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeMagicLogin, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+	app.resetAttempts(r, magicLinkAttempt(user.Email))
+
+	if !user.Activated {
+		app.inactiveAccountResponse(w, r)
+		return
+	}
+
+	app.issueAuthenticationTokens(w, r, user, input.DeviceName, "magic_link")
+}
+
+func (app *application) refreshAuthenticationTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var input struct {
+		TokenPlaintext string `json:"refresh_token"`
+	}
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	v := validator.New()
+	if data.ValidateTokenPlaintext(v, input.TokenPlaintext); !v.Valid() {
+		app.failedValidationResponse(w, r, v.Errors)
+		return
+	}
+
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
+	token, refreshToken, revoked, err := app.models.Tokens.Rotate(input.TokenPlaintext, app.config.tokens.accessTTL, app.config.tokens.refreshTTL, r)
+	if err != nil {
+		switch {
+		case errors.Is(err, data.ErrTokenReused):
+			err = app.revokeTokenJWTs(revoked)
+			if err != nil {
+				app.serverErrorResponse(w, r, err)
+				return
+			}
+			app.logSecurityEvent(r, "refresh_token_reuse", map[string]string{})
+			v.AddError("token", "invalid or expired refresh token")
+			app.failedValidationResponse(w, r, v.Errors)
+		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
+			v.AddError("token", "invalid or expired refresh token")
+			app.failedValidationResponse(w, r, v.Errors)
+		default:
+			app.serverErrorResponse(w, r, err)
+		}
+		return
+	}
+
+	// The authentication token the rotation replaced is gone, and so must be
+	// the signed token issued for it.
+	err = app.revokeTokenJWTs(revoked)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.signAccessToken(token)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusCreated, envelope{"authentication_token": token}, nil)
+	env := envelope{"authentication_token": token, "refresh_token": refreshToken}
+	err = app.writeJSON(w, http.StatusCreated, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -19,7 +19,7 @@ func TestCreateAuthenticationToken(t *testing.T) {
 		wantCode int
 		wantBody []byte
 	}{
-		{"Created", "olalekanawe99@gmail.com", "1234567890", http.StatusCreated, []byte("token")},
+		{"Created", "olalekanawe99@gmail.com", "1234567890", http.StatusCreated, []byte("refresh_token")},
 		{"InvalidEmail", "ola.com", "1234567890", http.StatusUnprocessableEntity, []byte("must be a valid email address")},
 		{"EmptyEmail", "", "1234567890", http.StatusUnprocessableEntity, []byte("\"email\": \"must be provided\"")},
 		{"UnknownEmail", "ola99@gmail.com", "1234567890", http.StatusUnauthorized, []byte("invalid authentication credentials")},
@@ -65,6 +65,60 @@ func TestCreateAuthenticationToken(t *testing.T) {
 	}
 }
 
+func TestRefreshAuthenticationToken(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		token    string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Rotated", "RFR34GKUHNDUSJ3QRUT6IKWKRI", http.StatusCreated, []byte("refresh_token")},
+		{"Reused", "RFR34GKUHNDUSJ3QRUT6IKWKRU", http.StatusUnprocessableEntity, []byte("invalid or expired refresh token")},
+		{"Unknown", "RFR34GKUHNDUSJ3QRUT6IKWKRX", http.StatusUnprocessableEntity, []byte("invalid or expired refresh token")},
+		{"Empty", "", http.StatusUnprocessableEntity, []byte("\"token\": \"must be provided\"")},
+		{"Short", "RFR34GKUHND", http.StatusUnprocessableEntity, []byte("must be 26 bytes long")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := struct {
+				RefreshToken string `json:"refresh_token"`
+			}{
+				RefreshToken: tt.token,
+			}
+
+			payload, err := json.Marshal(input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req, err := http.NewRequest(http.MethodPost, ts.URL+"/v1/tokens/refresh", bytes.NewReader(payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
 func TestCreateActivationToken(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
@@ -170,3 +224,55 @@ func TestPasswordResetToken(t *testing.T) {
 		})
 	}
 }
+
+func TestMagicLink(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	sent := []byte("if an activated account with this email address exists, an email will be sent to it containing a sign in token")
+
+	tests := []struct {
+		name     string
+		method   string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Request", http.MethodPost, `{"email": "olalekanawe99@gmail.com"}`, http.StatusAccepted, sent},
+		{"RequestUnknownEmail", http.MethodPost, `{"email": "nobody@gmail.com"}`, http.StatusAccepted, sent},
+		{"RequestNotActivated", http.MethodPost, `{"email": "ayo@gmail.com"}`, http.StatusAccepted, sent},
+		{"RequestLocked", http.MethodPost, `{"email": "locked@gmail.com"}`, http.StatusTooManyRequests, []byte("too many failed attempts, please try again later")},
+		{"RequestTooSoon", http.MethodPost, `{"email": "slow@gmail.com"}`, http.StatusTooManyRequests, []byte("too many failed attempts, please try again later")},
+		{"RequestInvalidEmail", http.MethodPost, `{"email": "ola.com"}`, http.StatusUnprocessableEntity, []byte("must be a valid email address")},
+		{"Exchange", http.MethodPut, `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRI"}`, http.StatusCreated, []byte("refresh_token")},
+		{"ExchangeMFA", http.MethodPut, `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRN"}`, http.StatusCreated, []byte("mfa_token")},
+		{"ExchangeNotActivated", http.MethodPut, `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRJ"}`, http.StatusForbidden, []byte("your user account must be activated to access this resource")},
+		{"ExchangeInvalidToken", http.MethodPut, `{"token": "HTE34GKUHNDUSJ3QRUT6IKWKRX"}`, http.StatusUnprocessableEntity, []byte("invalid or expired sign in token")},
+		{"ExchangeNoToken", http.MethodPut, `{}`, http.StatusUnprocessableEntity, []byte("\"token\": \"must be provided\"")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, ts.URL+"/v1/tokens/magic-link", bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"testing"
+)
+
+func TestListTrash(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"Owner", http.StatusOK, []byte("Deleted Movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/trash"},
+		{"Sorted", http.StatusOK, []byte("deleted_at"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/trash?sort=title&page=1&page_size=5"},
+		{"OtherUser", http.StatusOK, []byte("\"movies\": []"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM", "/v1/movies/trash"},
+		{"FailedValidation", http.StatusUnprocessableEntity, []byte("invalid sort value"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/trash?sort=year"},
+		{"UnAuthenticated", http.StatusUnauthorized, []byte("you must be authenticated to access this resource"), "", "/v1/movies/trash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestRestoreMovie(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		wantCode int
+		wantBody []byte
+		token    string
+		urlPath  string
+	}{
+		{"Restored", http.StatusOK, []byte("Deleted Movie"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/3/restore"},
+		{"RestoredNewVersion", http.StatusOK, []byte("\"version\": 2"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/3/restore"},
+		{"NotInTrash", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/1/restore"},
+		{"NotFoundFoo", http.StatusNotFound, []byte("the requested resource could not be found"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", "/v1/movies/foo/restore"},
+		{"NotPermitted", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRM", "/v1/movies/3/restore"},
+		{"ReadOnly", http.StatusForbidden, []byte("your user account is not permitted to access this resource"), "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRL", "/v1/movies/3/restore"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, ts.URL+tt.urlPath, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Set("Authorization", tt.token)
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
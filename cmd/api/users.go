@@ -1,13 +1,14 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/data"
 	"github.com/IfedayoAwe/greenlight/internal/validator"
-	"github.com/tomasen/realip"
 )
 
 func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Request) {
@@ -56,18 +57,17 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.auditAs(r, &user.ID, "user.register", "user", user.ID, nil, user)
+
+	role := "viewer"
 	if input.Role == "contributor" {
-		err = app.models.Permissions.AddForUser(user.ID, "movies:read", "movies:write")
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
-	} else {
-		err = app.models.Permissions.AddForUser(user.ID, "movies:read")
-		if err != nil {
-			app.serverErrorResponse(w, r, err)
-			return
-		}
+		role = "contributor"
+	}
+
+	err = app.models.Permissions.AddRolesForUser(user.ID, role)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
 	}
 
 	app.background(func() {
@@ -126,10 +126,15 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
 	user, err := app.models.Users.GetForToken(data.ScopeActivation, input.TokenPlaintext)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
 			v.AddError("token", "invalid or expired activation token")
 			app.failedValidationResponse(w, r, v.Errors)
 		default:
@@ -157,6 +162,8 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	app.auditAs(r, &user.ID, "user.activate", "user", user.ID, nil, nil)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -202,19 +209,39 @@ func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	err = app.revokeUserJWTs(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, user.ID, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	token, err := app.models.Tokens.New(user.ID, 24*time.Hour, data.ScopeAuthentication, r)
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeRefresh, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	token, refreshToken, err := app.models.Tokens.NewPair(user.ID, "", app.config.tokens.accessTTL, app.config.tokens.refreshTTL, r)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	err = app.signAccessToken(token)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"token": token}, nil)
+	app.audit(r, "user.password_change", "user", user.ID, nil, nil)
+
+	err = app.writeJSON(w, http.StatusOK, envelope{"token": token, "refresh_token": refreshToken}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -241,10 +268,15 @@ func (app *application) resetUserPasswordHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	if !app.checkAttempts(w, r, ipAttempt(r)) {
+		return
+	}
+
 	user, err := app.models.Users.GetForToken(data.ScopePasswordReset, input.TokenPlaintext)
 	if err != nil {
 		switch {
 		case errors.Is(err, data.ErrRecordNotFound):
+			app.recordFailedAttempt(r, ipAttempt(r))
 			v.AddError("token", "invalid or expired password reset token")
 			app.failedValidationResponse(w, r, v.Errors)
 		default:
@@ -276,12 +308,26 @@ func (app *application) resetUserPasswordHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	err = app.revokeUserJWTs(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, user.ID, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	err = app.models.Tokens.DeleteAllForUser(data.ScopeRefresh, user.ID, nil)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.auditAs(r, &user.ID, "user.password_reset", "user", user.ID, nil, nil)
+
 	env := envelope{"message": "your password was successfully reset"}
 	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
@@ -302,21 +348,42 @@ func (app *application) updateUserDetailsHandler(w http.ResponseWriter, r *http.
 	}
 
 	user := app.contextGetUser(r)
+	before := *user
 
 	if input.Name != nil {
 		user.Name = *input.Name
 	}
 
-	if input.Email != nil {
-		user.Email = *input.Email
-	}
-
 	v := validator.New()
 	if data.ValidateUser(v, user); !v.Valid() {
 		app.failedValidationResponse(w, r, v.Errors)
 		return
 	}
 
+	// A new email address is only stored as pending until it is confirmed
+	// with the token sent to it, so that a hijacked session cannot quietly
+	// take the account over.
+	emailChanged := input.Email != nil && !strings.EqualFold(*input.Email, user.Email)
+	if emailChanged {
+		if data.ValidateEmail(v, *input.Email); !v.Valid() {
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		}
+
+		_, err := app.models.Users.GetByEmail(*input.Email)
+		switch {
+		case err == nil:
+			v.AddError("email", "a user with this email address already exists")
+			app.failedValidationResponse(w, r, v.Errors)
+			return
+		case !errors.Is(err, data.ErrRecordNotFound):
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		user.PendingEmail = *input.Email
+	}
+
 	err = app.models.Users.Update(user)
 	if err != nil {
 		switch {
@@ -331,7 +398,21 @@ func (app *application) updateUserDetailsHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
-	err = app.writeJSON(w, http.StatusOK, envelope{"user": user}, nil)
+	app.audit(r, "user.update", "user", user.ID, before, user)
+
+	env := envelope{"user": user}
+
+	if emailChanged {
+		err = app.sendEmailChangeTokens(r, user)
+		if err != nil {
+			app.serverErrorResponse(w, r, err)
+			return
+		}
+
+		env["message"] = "an email will be sent to your new email address containing instructions to confirm it"
+	}
+
+	err = app.writeJSON(w, http.StatusOK, env, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 	}
@@ -340,13 +421,24 @@ func (app *application) updateUserDetailsHandler(w http.ResponseWriter, r *http.
 
 func (app *application) userLogoutHandler(w http.ResponseWriter, r *http.Request) {
 	user := app.contextGetUser(r)
-	ip := realip.FromRequest(r)
-	err := app.models.Tokens.DeleteAllForUser(data.ScopeAuthentication, user.ID, &ip)
+	current := app.contextGetToken(r)
+
+	err := app.revokeSessionJWTs(user.ID, func(session *data.Session) bool {
+		return bytes.Equal(session.Hash, current)
+	})
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
 		return
 	}
 
+	err = app.models.Tokens.DeleteSessionForToken(user.ID, current)
+	if err != nil && !errors.Is(err, data.ErrRecordNotFound) {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
+	app.audit(r, "user.logout", "user", user.ID, nil, nil)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user sucessfully logged out"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -374,6 +466,12 @@ func (app *application) deleteUserAccountHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	err = app.revokeUserJWTs(user.ID)
+	if err != nil {
+		app.serverErrorResponse(w, r, err)
+		return
+	}
+
 	err = app.models.Users.Delete(user.ID)
 	if err != nil {
 		switch {
@@ -385,6 +483,8 @@ func (app *application) deleteUserAccountHandler(w http.ResponseWriter, r *http.
 		return
 	}
 
+	app.audit(r, "user.delete", "user", user.ID, user, nil)
+
 	err = app.writeJSON(w, http.StatusOK, envelope{"message": "user account successfully deleted"}, nil)
 	if err != nil {
 		app.serverErrorResponse(w, r, err)
@@ -4,7 +4,11 @@ import (
 	"bytes"
 	"encoding/json"
 	"net/http"
+	"strings"
 	"testing"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+	"github.com/IfedayoAwe/greenlight/internal/data/mock"
 )
 
 func TestRegisterUser(t *testing.T) {
@@ -281,7 +285,7 @@ func TestUpdateUserDetails(t *testing.T) {
 	user1 := struct{ Name string }{"Jerry"}
 	user2 := struct{ Email string }{"jerry@gmail.com"}
 	user3 := struct{ Foo string }{"jerry@gmail.com"}
-	user4 := struct{ Email string }{"foo@gmail.com"}
+	user4 := struct{ Email string }{"ayo@gmail.com"}
 	user5 := struct{ Name string }{""}
 
 	tests := []struct {
@@ -292,7 +296,7 @@ func TestUpdateUserDetails(t *testing.T) {
 		user     interface{}
 	}{
 		{"Name", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusOK, []byte("Jerry"), user1},
-		{"Email", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusOK, []byte("jerry@gmail.com"), user2},
+		{"Email", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusOK, []byte(`"pending_email": "jerry@gmail.com"`), user2},
 		{"Foo", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusBadRequest, []byte("body contains unknown key"), user3},
 		{"DuplicateEmail", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusUnprocessableEntity, []byte("a user with this email address already exists"), user4},
 		{"EmptyName", "Bearer HTE34GKUHNDUSJ3QRUT6IKWKRI", http.StatusUnprocessableEntity, []byte("must be provided"), user5},
@@ -331,6 +335,106 @@ func TestUpdateUserDetails(t *testing.T) {
 	}
 }
 
+func TestChangeEmail(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		urlPath  string
+		payload  string
+		wantCode int
+		wantBody []byte
+	}{
+		{"Confirm", "/v1/users/email", `{"token": "EML34GKUHNDUSJ3QRUT6IKWKRL"}`, http.StatusOK, []byte(`"email": "vicky.new@gmail.com"`)},
+		{"ConfirmDuplicateEmail", "/v1/users/email", `{"token": "EML34GKUHNDUSJ3QRUT6IKWKRM"}`, http.StatusUnprocessableEntity, []byte("a user with this email address already exists")},
+		{"ConfirmInvalidToken", "/v1/users/email", `{"token": "EML34GKUHNDUSJ3QRUT6IKWKRX"}`, http.StatusUnprocessableEntity, []byte("invalid or expired email change token")},
+		{"ConfirmNoToken", "/v1/users/email", `{}`, http.StatusUnprocessableEntity, []byte("\"token\": \"must be provided\"")},
+		{"RevertPending", "/v1/users/email/revert", `{"token": "REV34GKUHNDUSJ3QRUT6IKWKRK"}`, http.StatusOK, []byte("please sign in again")},
+		{"RevertConfirmed", "/v1/users/email/revert", `{"token": "REV34GKUHNDUSJ3QRUT6IKWKRL"}`, http.StatusOK, []byte("please sign in again")},
+		{"RevertChangedAgain", "/v1/users/email/revert", `{"token": "REV34GKUHNDUSJ3QRUT6IKWKRM"}`, http.StatusOK, []byte("please sign in again")},
+		{"RevertEmailTaken", "/v1/users/email/revert", `{"token": "REV34GKUHNDUSJ3QRUT6IKWKRN"}`, http.StatusUnprocessableEntity, []byte("a user with this email address already exists")},
+		{"RevertInvalidToken", "/v1/users/email/revert", `{"token": "REV34GKUHNDUSJ3QRUT6IKWKRX"}`, http.StatusUnprocessableEntity, []byte("invalid or expired email revert token")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, ts.URL+tt.urlPath, bytes.NewReader([]byte(tt.payload)))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			req.Header.Add("Content-Type", "application/json")
+
+			code, header, body := ts.do(t, req)
+			if contentType := header.Get("Content-Type"); contentType != "application/json" {
+				t.Errorf("want %q; got %q", "application/json", contentType)
+			}
+
+			if code != tt.wantCode {
+				t.Errorf("want %d; got %d", tt.wantCode, code)
+			}
+
+			if !bytes.Contains(body, tt.wantBody) {
+				t.Errorf("want body to contain %q", tt.wantBody)
+			}
+		})
+	}
+}
+
+// TestRevertEmailChangeTwice covers an account taken over by changing its
+// email address and then changing it again: the revert token sent to the
+// original address must still restore it, not the address in between.
+func TestRevertEmailChangeTwice(t *testing.T) {
+	app := newTestApplication(t)
+	ts := newTestServer(t, app.routes())
+	defer ts.Close()
+
+	tests := []struct {
+		name      string
+		token     string
+		wantEmail string
+	}{
+		{"Pending", "REV34GKUHNDUSJ3QRUT6IKWKRK", "vicky@gmail.com"},
+		{"Confirmed", "REV34GKUHNDUSJ3QRUT6IKWKRL", "vicky@gmail.com"},
+		{"ChangedAgain", "REV34GKUHNDUSJ3QRUT6IKWKRM", "vicky@gmail.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPut, ts.URL+"/v1/users/email/revert", strings.NewReader(`{"token": "`+tt.token+`"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			code, _, _ := ts.do(t, req)
+			if code != http.StatusOK {
+				t.Fatalf("want %d; got %d", http.StatusOK, code)
+			}
+
+			events := app.models.Audit.(*mock.MockAuditModel).Events
+			event := events[len(events)-1]
+			if event.Action != "user.email_revert" {
+				t.Fatalf("want %q; got %q", "user.email_revert", event.Action)
+			}
+
+			var after data.User
+			err = json.Unmarshal(event.After, &after)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if after.Email != tt.wantEmail {
+				t.Errorf("want email %q; got %q", tt.wantEmail, after.Email)
+			}
+			if after.PendingEmail != "" {
+				t.Errorf("want no pending email; got %q", after.PendingEmail)
+			}
+		})
+	}
+}
+
 func TestUserLogout(t *testing.T) {
 	app := newTestApplication(t)
 	ts := newTestServer(t, app.routes())
@@ -0,0 +1,146 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+const (
+	AttemptEmail = "email"
+	AttemptIP    = "ip"
+	// AttemptMagicLink counts the sign in links requested for an email
+	// address, so that an inbox cannot be flooded with them.
+	AttemptMagicLink = "magic_link"
+)
+
+// LoginAttempts tracks the recent failed sign in attempts for an email
+// address or an IP address.
+type LoginAttempts struct {
+	Kind          string
+	Key           string
+	Failures      int
+	LastFailureAt time.Time
+	LockedUntil   time.Time
+}
+
+type AttemptModel struct {
+	DB *sql.DB
+}
+
+// Get returns the failed attempts recorded for key, with no failures if there
+// are none.
+func (m AttemptModel) Get(kind, key string) (*LoginAttempts, error) {
+	query := `
+	SELECT kind, key, failures, last_failure_at, COALESCE(locked_until, to_timestamp(0))
+	FROM login_attempts
+	WHERE kind = $1 AND key = $2`
+
+	attempts := LoginAttempts{Kind: kind, Key: key}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, kind, key).Scan(
+		&attempts.Kind,
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+		&attempts.LockedUntil,
+	)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	return &attempts, nil
+}
+
+// RecordFailure counts a failed attempt for key, starting the count again if
+// the last failure is older than window, and locks key for window once
+// threshold failures have been counted.
+func (m AttemptModel) RecordFailure(kind, key string, threshold int, window time.Duration) (*LoginAttempts, error) {
+	query := `
+	INSERT INTO login_attempts (kind, key, failures, last_failure_at)
+	VALUES ($1, $2, 1, NOW())
+	ON CONFLICT (kind, key) DO UPDATE
+	SET failures = CASE
+			WHEN login_attempts.last_failure_at < $3 THEN 1
+			ELSE login_attempts.failures + 1
+		END,
+		last_failure_at = NOW()
+	RETURNING kind, key, failures, last_failure_at`
+
+	var attempts LoginAttempts
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, kind, key, time.Now().Add(-window)).Scan(
+		&attempts.Kind,
+		&attempts.Key,
+		&attempts.Failures,
+		&attempts.LastFailureAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if attempts.Failures >= threshold {
+		attempts.LockedUntil = attempts.LastFailureAt.Add(window)
+
+		query = `
+		UPDATE login_attempts
+		SET locked_until = $3, failures = 0
+		WHERE kind = $1 AND key = $2`
+
+		_, err = tx.ExecContext(ctx, query, kind, key, attempts.LockedUntil)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, err
+	}
+	return &attempts, nil
+}
+
+// Reset forgets the failed attempts and any lockout of key.
+func (m AttemptModel) Reset(kind, key string) error {
+	query := `
+	DELETE FROM login_attempts
+	WHERE kind = $1 AND key = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, kind, key)
+	return err
+}
+
+// DeleteStale removes the records whose last failure is older than window and
+// that are not locked, returning how many were removed.
+func (m AttemptModel) DeleteStale(window time.Duration) (int64, error) {
+	query := `
+	DELETE FROM login_attempts
+	WHERE last_failure_at < $1
+	AND (locked_until IS NULL OR locked_until < NOW())`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, time.Now().Add(-window))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
@@ -0,0 +1,305 @@
+package data
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// AuthCache is an in-process cache for the lookups made on every authenticated
+// request: the user an authentication token belongs to, the permissions a user
+// holds and the permissions that require two-factor authentication. Entries
+// live for at most ttl and the least recently used entries are evicted once a
+// cache holds maxEntries. The models invalidate the entries of a user whenever
+// their tokens, permissions or details change. A nil *AuthCache caches nothing.
+type AuthCache struct {
+	tokens       *lruCache
+	permissions  *lruCache
+	requiringMFA *lruCache
+}
+
+func NewAuthCache(ttl time.Duration, maxEntries int) *AuthCache {
+	return &AuthCache{
+		tokens:       newLRUCache(ttl, maxEntries),
+		permissions:  newLRUCache(ttl, maxEntries),
+		requiringMFA: newLRUCache(ttl, 1),
+	}
+}
+
+// GenreCache is an in-process cache for the genre index, which is read
+// whenever the genres of movies are validated or filtered on. The genre model
+// invalidates it whenever a genre is created, updated or merged. A nil
+// *GenreCache caches nothing.
+type GenreCache struct {
+	index *lruCache
+}
+
+func NewGenreCache(ttl time.Duration) *GenreCache {
+	return &GenreCache{index: newLRUCache(ttl, 1)}
+}
+
+// Stats returns the hit and miss counters of the cache, in a shape suitable
+// for publishing through expvar.
+func (c *GenreCache) Stats() interface{} {
+	if c == nil {
+		return CacheStats{}
+	}
+	return c.index.stats()
+}
+
+// genreIndexKey is the only key of the genre cache.
+const genreIndexKey = "index"
+
+// getIndex returns the cached index, which is shared and must not be modified.
+func (c *GenreCache) getIndex() (GenreIndex, uint64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	value, gen, ok := c.index.get(genreIndexKey)
+	if !ok {
+		return nil, gen, false
+	}
+	return value.(GenreIndex), gen, true
+}
+
+func (c *GenreCache) setIndex(index GenreIndex, gen uint64) {
+	if c == nil {
+		return
+	}
+	c.index.set(genreIndexKey, index, 0, time.Time{}, gen)
+}
+
+func (c *GenreCache) invalidate() {
+	if c == nil {
+		return
+	}
+	c.index.clear()
+}
+
+type CacheStats struct {
+	Hits    int64 `json:"hits"`
+	Misses  int64 `json:"misses"`
+	Entries int   `json:"entries"`
+}
+
+// Stats returns the hit and miss counters of both caches, in a shape suitable
+// for publishing through expvar.
+func (c *AuthCache) Stats() interface{} {
+	if c == nil {
+		return map[string]CacheStats{}
+	}
+	return map[string]CacheStats{
+		"tokens":        c.tokens.stats(),
+		"permissions":   c.permissions.stats(),
+		"requiring_mfa": c.requiringMFA.stats(),
+	}
+}
+
+func (c *AuthCache) getUser(tokenHash []byte) (*User, uint64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	value, gen, ok := c.tokens.get(string(tokenHash))
+	if !ok {
+		return nil, gen, false
+	}
+	// Hand out a copy so that handlers changing the user do not change the
+	// cached one.
+	user := *value.(*User)
+	return &user, gen, true
+}
+
+func (c *AuthCache) setUser(tokenHash []byte, user *User, expiry time.Time, gen uint64) {
+	if c == nil {
+		return
+	}
+	cached := *user
+	c.tokens.set(string(tokenHash), &cached, user.ID, expiry, gen)
+}
+
+func (c *AuthCache) getPermissions(userID int64) (Permissions, uint64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	value, gen, ok := c.permissions.get(userID)
+	if !ok {
+		return nil, gen, false
+	}
+	return append(Permissions{}, value.(Permissions)...), gen, true
+}
+
+func (c *AuthCache) setPermissions(userID int64, permissions Permissions, gen uint64) {
+	if c == nil {
+		return
+	}
+	c.permissions.set(userID, append(Permissions{}, permissions...), userID, time.Time{}, gen)
+}
+
+// requiringMFAKey is the only key of the requiringMFA cache, which holds a
+// single set of permissions shared by every user.
+const requiringMFAKey = "requiring_mfa"
+
+func (c *AuthCache) getRequiringMFA() (Permissions, uint64, bool) {
+	if c == nil {
+		return nil, 0, false
+	}
+	value, gen, ok := c.requiringMFA.get(requiringMFAKey)
+	if !ok {
+		return nil, gen, false
+	}
+	return append(Permissions{}, value.(Permissions)...), gen, true
+}
+
+func (c *AuthCache) setRequiringMFA(permissions Permissions, gen uint64) {
+	if c == nil {
+		return
+	}
+	c.requiringMFA.set(requiringMFAKey, append(Permissions{}, permissions...), 0, time.Time{}, gen)
+}
+
+// invalidateRequiringMFA drops the cached permissions that require two-factor
+// authentication.
+func (c *AuthCache) invalidateRequiringMFA() {
+	if c == nil {
+		return
+	}
+	c.requiringMFA.clear()
+}
+
+// invalidateTokens drops every cached token lookup of the user.
+func (c *AuthCache) invalidateTokens(userID int64) {
+	if c == nil {
+		return
+	}
+	c.tokens.removeUser(userID)
+}
+
+// invalidatePermissions drops the cached permissions of the user.
+func (c *AuthCache) invalidatePermissions(userID int64) {
+	if c == nil {
+		return
+	}
+	c.permissions.removeUser(userID)
+}
+
+// invalidateAllPermissions drops every cached permission set, for changes such
+// as editing a role that affect an unknown number of users.
+func (c *AuthCache) invalidateAllPermissions() {
+	if c == nil {
+		return
+	}
+	c.permissions.clear()
+}
+
+type lruEntry struct {
+	key     interface{}
+	value   interface{}
+	userID  int64
+	expires time.Time
+}
+
+// lruCache is a mutex guarded least-recently-used cache with a per entry
+// expiry. Every invalidation bumps gen, and a value read from the database is
+// only stored if gen has not moved since the lookup missed, so a lookup racing
+// with an invalidation cannot put a stale value back into the cache.
+type lruCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	ll         *list.List
+	items      map[interface{}]*list.Element
+	gen        uint64
+	hits       int64
+	misses     int64
+}
+
+func newLRUCache(ttl time.Duration, maxEntries int) *lruCache {
+	return &lruCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[interface{}]*list.Element),
+	}
+}
+
+func (c *lruCache) get(key interface{}) (interface{}, uint64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		entry := el.Value.(*lruEntry)
+		if time.Now().Before(entry.expires) {
+			c.ll.MoveToFront(el)
+			c.hits++
+			return entry.value, c.gen, true
+		}
+		c.removeElement(el)
+	}
+
+	c.misses++
+	return nil, c.gen, false
+}
+
+// set stores value under key until the cache ttl elapses, or until expiry if
+// that is sooner and not zero.
+func (c *lruCache) set(key, value interface{}, userID int64, expiry time.Time, gen uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if gen != c.gen || c.maxEntries < 1 {
+		return
+	}
+
+	expires := time.Now().Add(c.ttl)
+	if !expiry.IsZero() && expiry.Before(expires) {
+		expires = expiry
+	}
+
+	if el, ok := c.items[key]; ok {
+		c.ll.MoveToFront(el)
+		entry := el.Value.(*lruEntry)
+		entry.value, entry.userID, entry.expires = value, userID, expires
+		return
+	}
+
+	c.items[key] = c.ll.PushFront(&lruEntry{key: key, value: value, userID: userID, expires: expires})
+
+	for c.ll.Len() > c.maxEntries {
+		c.removeElement(c.ll.Back())
+	}
+}
+
+func (c *lruCache) removeUser(userID int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	for el := c.ll.Front(); el != nil; {
+		next := el.Next()
+		if el.Value.(*lruEntry).userID == userID {
+			c.removeElement(el)
+		}
+		el = next
+	}
+}
+
+func (c *lruCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.gen++
+	c.ll.Init()
+	c.items = make(map[interface{}]*list.Element)
+}
+
+func (c *lruCache) removeElement(el *list.Element) {
+	c.ll.Remove(el)
+	delete(c.items, el.Value.(*lruEntry).key)
+}
+
+func (c *lruCache) stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return CacheStats{Hits: c.hits, Misses: c.misses, Entries: c.ll.Len()}
+}
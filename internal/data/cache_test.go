@@ -0,0 +1,187 @@
+package data
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+	c := newLRUCache(time.Minute, 2)
+
+	_, gen, _ := c.get("a")
+	c.set("a", 1, 1, time.Time{}, gen)
+	c.set("b", 2, 2, time.Time{}, gen)
+
+	// Reading a makes b the least recently used entry.
+	if _, _, ok := c.get("a"); !ok {
+		t.Fatal("want a to be cached")
+	}
+
+	c.set("c", 3, 3, time.Time{}, gen)
+
+	tests := []struct {
+		key    string
+		wantOK bool
+	}{
+		{"a", true},
+		{"b", false},
+		{"c", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if _, _, ok := c.get(tt.key); ok != tt.wantOK {
+				t.Errorf("want ok %t; got %t", tt.wantOK, ok)
+			}
+		})
+	}
+
+	if entries := c.stats().Entries; entries != 2 {
+		t.Errorf("want %d entries; got %d", 2, entries)
+	}
+}
+
+func TestLRUCacheExpiry(t *testing.T) {
+	c := newLRUCache(time.Minute, 10)
+
+	_, gen, _ := c.get("a")
+	c.set("a", 1, 1, time.Now().Add(-time.Second), gen)
+	c.set("b", 2, 2, time.Time{}, gen)
+
+	if _, _, ok := c.get("a"); ok {
+		t.Error("want a past its expiry not to be cached")
+	}
+	if _, _, ok := c.get("b"); !ok {
+		t.Error("want b to be cached")
+	}
+}
+
+func TestLRUCacheGeneration(t *testing.T) {
+	tests := []struct {
+		name       string
+		invalidate func(c *lruCache)
+	}{
+		{"RemoveUser", func(c *lruCache) { c.removeUser(1) }},
+		{"RemoveOtherUser", func(c *lruCache) { c.removeUser(2) }},
+		{"Clear", func(c *lruCache) { c.clear() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newLRUCache(time.Minute, 10)
+
+			// A lookup misses, an invalidation happens while the database is
+			// queried, and the now stale result must not be stored.
+			_, gen, _ := c.get("a")
+			tt.invalidate(c)
+			c.set("a", 1, 1, time.Time{}, gen)
+
+			if _, _, ok := c.get("a"); ok {
+				t.Error("want a value read before the invalidation not to be cached")
+			}
+
+			_, gen, _ = c.get("a")
+			c.set("a", 1, 1, time.Time{}, gen)
+
+			if _, _, ok := c.get("a"); !ok {
+				t.Error("want a value read after the invalidation to be cached")
+			}
+		})
+	}
+}
+
+func TestLRUCacheRemoveUser(t *testing.T) {
+	c := newLRUCache(time.Minute, 10)
+
+	_, gen, _ := c.get("a")
+	c.set("a", 1, 1, time.Time{}, gen)
+	c.set("b", 2, 1, time.Time{}, gen)
+	c.set("c", 3, 2, time.Time{}, gen)
+
+	c.removeUser(1)
+
+	for key, wantOK := range map[string]bool{"a": false, "b": false, "c": true} {
+		if _, _, ok := c.get(key); ok != wantOK {
+			t.Errorf("%s: want ok %t; got %t", key, wantOK, ok)
+		}
+	}
+}
+
+func TestAuthCachePermissions(t *testing.T) {
+	c := NewAuthCache(time.Minute, 10)
+
+	_, gen, ok := c.getPermissions(1)
+	if ok {
+		t.Fatal("want an empty cache to miss")
+	}
+	c.setPermissions(1, Permissions{"movies:read"}, gen)
+
+	permissions, _, ok := c.getPermissions(1)
+	if !ok {
+		t.Fatal("want the permissions to be cached")
+	}
+	if want := (Permissions{"movies:read"}); !reflect.DeepEqual(permissions, want) {
+		t.Errorf("want %v; got %v", want, permissions)
+	}
+
+	// The cache hands out copies, so changing one must not change the cache.
+	permissions[0] = "movies:write"
+	if permissions, _, _ = c.getPermissions(1); permissions[0] != "movies:read" {
+		t.Errorf("want %q; got %q", "movies:read", permissions[0])
+	}
+
+	c.invalidatePermissions(1)
+	if _, _, ok := c.getPermissions(1); ok {
+		t.Error("want the permissions to be invalidated")
+	}
+}
+
+func TestNilAuthCache(t *testing.T) {
+	var c *AuthCache
+
+	_, gen, _ := c.getPermissions(1)
+	c.setPermissions(1, Permissions{"movies:read"}, gen)
+	if _, _, ok := c.getPermissions(1); ok {
+		t.Error("want a nil cache to cache nothing")
+	}
+
+	c.invalidatePermissions(1)
+	c.invalidateTokens(1)
+	c.invalidateAllPermissions()
+}
+
+func TestGenreCache(t *testing.T) {
+	c := NewGenreCache(time.Minute)
+
+	_, gen, ok := c.getIndex()
+	if ok {
+		t.Fatal("want an empty cache to miss")
+	}
+	c.setIndex(GenreIndex{"sci-fi": "science-fiction"}, gen)
+
+	index, _, ok := c.getIndex()
+	if !ok {
+		t.Fatal("want the index to be cached")
+	}
+	if slug, _ := index.Normalise("Sci-Fi"); slug != "science-fiction" {
+		t.Errorf("want %q; got %q", "science-fiction", slug)
+	}
+
+	// A lookup racing with a change to the genres must not be stored.
+	_, gen, _ = c.getIndex()
+	c.invalidate()
+	c.setIndex(GenreIndex{"sci-fi": "sci-fi"}, gen)
+
+	if _, _, ok := c.getIndex(); ok {
+		t.Error("want the index to be invalidated")
+	}
+
+	var nilCache *GenreCache
+	_, gen, _ = nilCache.getIndex()
+	nilCache.setIndex(GenreIndex{}, gen)
+	if _, _, ok := nilCache.getIndex(); ok {
+		t.Error("want a nil cache to cache nothing")
+	}
+	nilCache.invalidate()
+}
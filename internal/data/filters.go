@@ -12,14 +12,30 @@ type Filters struct {
 	PageSize     int
 	Sort         string
 	SortSafelist []string
+	Cursor       *Cursor
+}
+
+// Cursor marks a position in a keyset paginated listing. Value holds the
+// value of the sort column of the row at that position and ID breaks ties
+// between rows sharing the same value. A Cursor with a zero ID starts from
+// the beginning of the listing, and Before walks backwards from the position.
+type Cursor struct {
+	Sort   string `json:"s"`
+	Value  string `json:"v"`
+	ID     int64  `json:"id"`
+	Before bool   `json:"b,omitempty"`
 }
 
 type Metadata struct {
-	CurrentPage  int `json:"current_page,omitempty"`
-	PageSize     int `json:"page_size,omitempty"`
-	FirstPage    int `json:"first_page,omitempty"`
-	LastPage     int `json:"last_page,omitempty"`
-	TotalRecords int `json:"total_records,omitempty"`
+	CurrentPage  int     `json:"current_page,omitempty"`
+	PageSize     int     `json:"page_size,omitempty"`
+	FirstPage    int     `json:"first_page,omitempty"`
+	LastPage     int     `json:"last_page,omitempty"`
+	TotalRecords int     `json:"total_records,omitempty"`
+	NextCursor   string  `json:"next_cursor,omitempty"`
+	PrevCursor   string  `json:"prev_cursor,omitempty"`
+	Next         *Cursor `json:"-"`
+	Prev         *Cursor `json:"-"`
 }
 
 func ValidateFilters(v *validator.Validator, f Filters) {
@@ -46,6 +62,19 @@ func (f Filters) sortDirection() string {
 	return "ASC"
 }
 
+// keyset returns the comparison operator and the ORDER BY direction used to
+// continue a listing from f.Cursor.
+func (f Filters) keyset() (string, string) {
+	forward := !f.Cursor.Before
+	ascending := f.sortDirection() == "ASC"
+	switch {
+	case forward == ascending:
+		return ">", "ASC"
+	default:
+		return "<", "DESC"
+	}
+}
+
 func (f Filters) limit() int {
 	return f.PageSize
 }
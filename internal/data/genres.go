@@ -0,0 +1,410 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateGenre = errors.New("duplicate genre")
+
+var (
+	SlugRX = regexp.MustCompile("^[a-z0-9]+(-[a-z0-9]+)*$")
+
+	nonSlugRX = regexp.MustCompile("[^a-z0-9]+")
+)
+
+// Slugify turns a genre as written by users, e.g. "Sci-Fi" or "science
+// fiction", into the form genres are stored and looked up in. It matches the
+// normalisation done in the migration creating the genres table.
+func Slugify(s string) string {
+	return strings.Trim(nonSlugRX.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+// Genre is a genre of the managed taxonomy. Movies store the slugs of their
+// genres, aliases are other spellings that are normalised to the slug.
+type Genre struct {
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"-"`
+	Slug       string    `json:"slug"`
+	Name       string    `json:"name"`
+	Aliases    []string  `json:"aliases"`
+	MovieCount int64     `json:"movie_count"`
+	Version    int32     `json:"version"`
+}
+
+func ValidateGenre(v *validator.Validator, genre *Genre) {
+	v.Check(genre.Name != "", "name", "must be provided")
+	v.Check(len(genre.Name) <= 50, "name", "must not be more than 50 bytes long")
+	v.Check(genre.Slug != "", "slug", "must be provided")
+	v.Check(len(genre.Slug) <= 50, "slug", "must not be more than 50 bytes long")
+	v.Check(validator.Matches(genre.Slug, SlugRX), "slug", "must only contain lowercase letters, digits and single hyphens")
+	v.Check(len(genre.Aliases) <= 20, "aliases", "must not contain more than 20 aliases")
+	v.Check(validator.Unique(genre.Aliases), "aliases", "must not contain duplicate values")
+	for _, alias := range genre.Aliases {
+		v.Check(alias != "", "aliases", "must not contain empty values")
+		v.Check(alias != genre.Slug, "aliases", "must not contain the slug of the genre")
+	}
+}
+
+// GenreIndex maps the slugs and aliases of the known genres to the slug of the
+// genre they belong to.
+type GenreIndex map[string]string
+
+// Normalise returns the slug of the known genre that genre is a spelling of.
+func (i GenreIndex) Normalise(genre string) (string, bool) {
+	slug, ok := i[Slugify(genre)]
+	return slug, ok
+}
+
+type GenreModel struct {
+	DB    *sql.DB
+	Cache *GenreCache
+}
+
+// Index returns the lookup table used to normalise the genres of movies. The
+// index may be shared with other callers and must not be modified.
+func (m GenreModel) Index() (GenreIndex, error) {
+	index, gen, ok := m.Cache.getIndex()
+	if ok {
+		return index, nil
+	}
+
+	query := `
+	SELECT slug, aliases
+	FROM genres`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	index = make(GenreIndex)
+
+	for rows.Next() {
+		var slug string
+		var aliases []string
+
+		err := rows.Scan(&slug, pq.Array(&aliases))
+		if err != nil {
+			return nil, err
+		}
+
+		index[slug] = slug
+		for _, alias := range aliases {
+			index[alias] = slug
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	m.Cache.setIndex(index, gen)
+
+	return index, nil
+}
+
+// GetAll returns every genre with the number of movies outside the trash it
+// is used by, ordered by name.
+func (m GenreModel) GetAll() ([]*Genre, error) {
+	query := `
+	SELECT id, created_at, slug, name, aliases, version,
+		(SELECT count(*) FROM movies WHERE movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL)
+	FROM genres
+	ORDER BY name, id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	genres := []*Genre{}
+
+	for rows.Next() {
+		var genre Genre
+
+		err := rows.Scan(
+			&genre.ID,
+			&genre.CreatedAt,
+			&genre.Slug,
+			&genre.Name,
+			pq.Array(&genre.Aliases),
+			&genre.Version,
+			&genre.MovieCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		genres = append(genres, &genre)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return genres, nil
+}
+
+func (m GenreModel) Get(id int64) (*Genre, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, slug, name, aliases, version,
+		(SELECT count(*) FROM movies WHERE movies.genres @> ARRAY[genres.slug] AND movies.deleted_at IS NULL)
+	FROM genres
+	WHERE id = $1`
+
+	var genre Genre
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&genre.ID,
+		&genre.CreatedAt,
+		&genre.Slug,
+		&genre.Name,
+		pq.Array(&genre.Aliases),
+		&genre.Version,
+		&genre.MovieCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &genre, nil
+}
+
+func (m GenreModel) Insert(genre *Genre) error {
+	query := `
+	INSERT INTO genres (slug, name, aliases)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{genre.Slug, genre.Name, pq.Array(genre.Aliases)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = checkGenreNames(ctx, tx, 0, genre)
+	if err != nil {
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.ID, &genre.CreatedAt, &genre.Version)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidate()
+
+	return nil
+}
+
+// Update saves the name, slug and aliases of the genre. When the slug changes
+// the movies using the genre are rewritten to the new one, recording a
+// revision of each on behalf of userID.
+func (m GenreModel) Update(genre *Genre, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var oldSlug string
+	err = tx.QueryRowContext(ctx, `SELECT slug FROM genres WHERE id = $1 AND version = $2 FOR UPDATE`, genre.ID, genre.Version).Scan(&oldSlug)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	err = checkGenreNames(ctx, tx, genre.ID, genre)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE genres
+	SET slug = $1, name = $2, aliases = $3, version = version + 1
+	WHERE id = $4
+	RETURNING version`
+
+	args := []interface{}{genre.Slug, genre.Name, pq.Array(genre.Aliases), genre.ID}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&genre.Version)
+	if err != nil {
+		return err
+	}
+
+	if oldSlug != genre.Slug {
+		err = rewriteGenre(ctx, tx, oldSlug, genre.Slug, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidate()
+
+	return nil
+}
+
+// Merge folds source into target: the movies using source are rewritten to
+// target, recording a revision of each on behalf of userID, the slug and
+// aliases of source become aliases of target and source is deleted.
+func (m GenreModel) Merge(source, target *Genre, userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM genres WHERE id = $1 AND version = $2`, source.ID, source.Version)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrEditConflict
+	}
+
+	aliases := append([]string{}, target.Aliases...)
+	for _, alias := range append([]string{source.Slug}, source.Aliases...) {
+		if alias != target.Slug && !validator.In(alias, aliases...) {
+			aliases = append(aliases, alias)
+		}
+	}
+
+	query := `
+	UPDATE genres
+	SET aliases = $1, version = version + 1
+	WHERE id = $2 AND version = $3
+	RETURNING version`
+
+	err = tx.QueryRowContext(ctx, query, pq.Array(aliases), target.ID, target.Version).Scan(&target.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	target.Aliases = aliases
+
+	err = rewriteGenre(ctx, tx, source.Slug, target.Slug, userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidate()
+
+	return nil
+}
+
+// checkGenreNames returns ErrDuplicateGenre if the slug or one of the aliases
+// of genre is already the slug or an alias of another genre, which would make
+// normalising it ambiguous.
+func checkGenreNames(ctx context.Context, tx *sql.Tx, id int64, genre *Genre) error {
+	query := `
+	SELECT EXISTS (
+		SELECT 1 FROM genres
+		WHERE id <> $1 AND (slug = ANY($2) OR aliases && $2)
+	)`
+
+	names := append([]string{genre.Slug}, genre.Aliases...)
+
+	var exists bool
+	err := tx.QueryRowContext(ctx, query, id, pq.Array(names)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+
+	if exists {
+		return ErrDuplicateGenre
+	}
+	return nil
+}
+
+// rewriteGenre replaces the genre from with to in every movie, including those
+// in the trash, dropping it where the movie already has to. Each movie changed
+// gets a new version and revision, so that ETags and the revision history
+// stay correct.
+func rewriteGenre(ctx context.Context, tx *sql.Tx, from, to string, userID int64) error {
+	query := `
+	WITH updated AS (
+		UPDATE movies
+		SET genres = ARRAY(
+			SELECT g.genre
+			FROM unnest(array_replace(movies.genres, $1, $2)) WITH ORDINALITY AS g(genre, position)
+			GROUP BY g.genre
+			ORDER BY min(g.position)
+		), version = version + 1
+		WHERE genres @> ARRAY[$1]
+		RETURNING id, version, title, year, runtime, genres
+	)
+	INSERT INTO movie_revisions (movie_id, version, user_id, action, title, year, runtime, genres)
+	SELECT id, version, $3, $4, title, year, runtime, genres
+	FROM updated`
+
+	_, err := tx.ExecContext(ctx, query, from, to, userID, RevisionUpdate)
+	return err
+}
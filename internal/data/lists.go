@@ -0,0 +1,518 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateListEntry = errors.New("duplicate list entry")
+
+// List is a named, ordered list of movies curated by a user. Private lists
+// are only visible to their owner.
+type List struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"user_id"`
+	CreatedAt   time.Time `json:"created_at"`
+	Name        string    `json:"name"`
+	Description string    `json:"description,omitempty"`
+	Public      bool      `json:"public"`
+	MovieCount  int64     `json:"movie_count"`
+	Version     int32     `json:"version"`
+}
+
+func ValidateList(v *validator.Validator, list *List) {
+	v.Check(list.Name != "", "name", "must be provided")
+	v.Check(len(list.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(list.Description) <= 2000, "description", "must not be more than 2000 bytes long")
+}
+
+// ListEntry is a movie on a list. Positions start at 1 and have no gaps.
+type ListEntry struct {
+	ListID   int64     `json:"-"`
+	MovieID  int64     `json:"movie_id"`
+	Position int32     `json:"position"`
+	Note     string    `json:"note,omitempty"`
+	AddedAt  time.Time `json:"added_at"`
+	Movie    *Movie    `json:"movie,omitempty"`
+}
+
+func ValidateListEntry(v *validator.Validator, entry *ListEntry) {
+	v.Check(entry.MovieID > 0, "movie_id", "must be provided")
+	v.Check(entry.Position >= 0, "position", "must be a positive integer")
+	v.Check(len(entry.Note) <= 500, "note", "must not be more than 500 bytes long")
+}
+
+type ListModel struct {
+	DB *sql.DB
+}
+
+func (m ListModel) Insert(list *List) error {
+	query := `
+	INSERT INTO lists (user_id, name, description, public)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{list.UserID, list.Name, list.Description, list.Public}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&list.ID, &list.CreatedAt, &list.Version)
+}
+
+func (m ListModel) Get(id int64) (*List, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, user_id, created_at, name, description, public, version,
+		(SELECT count(*) FROM list_entries WHERE list_entries.list_id = lists.id)
+	FROM lists
+	WHERE id = $1`
+
+	var list List
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&list.ID,
+		&list.UserID,
+		&list.CreatedAt,
+		&list.Name,
+		&list.Description,
+		&list.Public,
+		&list.Version,
+		&list.MovieCount,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &list, nil
+}
+
+func (m ListModel) Update(list *List) error {
+	query := `
+	UPDATE lists
+	SET name = $1, description = $2, public = $3, version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING version`
+
+	args := []interface{}{list.Name, list.Description, list.Public, list.ID, list.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&list.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m ListModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM lists
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll returns the lists viewerID can see, that is the public lists and
+// their own, optionally only those of ownerID and those whose name matches.
+func (m ListModel) GetAll(viewerID, ownerID int64, name string, filters Filters) ([]*List, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, user_id, created_at, name, description, public, version,
+		(SELECT count(*) FROM list_entries WHERE list_entries.list_id = lists.id)
+	FROM lists
+	WHERE (public OR user_id = $1)
+	AND (user_id = $2 OR $2 = 0)
+	AND (to_tsvector('simple', name) @@ plainto_tsquery('simple', $3) OR $3 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $4 OFFSET $5`, filters.sortColumn(), filters.sortDirection())
+
+	args := []interface{}{viewerID, ownerID, name, filters.limit(), filters.offset()}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	lists := []*List{}
+
+	for rows.Next() {
+		var list List
+
+		err := rows.Scan(
+			&totalRecords,
+			&list.ID,
+			&list.UserID,
+			&list.CreatedAt,
+			&list.Name,
+			&list.Description,
+			&list.Public,
+			&list.Version,
+			&list.MovieCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		lists = append(lists, &list)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return lists, metadata, nil
+}
+
+// GetEntries returns the movies on the list. Besides position, added_at and
+// the columns of the movies can be sorted on.
+func (m ListModel) GetEntries(listID int64, filters Filters) ([]*ListEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), movie_id, position, note, added_at, title, year, runtime, genres, version, rating, rating_count
+	FROM (
+		SELECT list_entries.movie_id, list_entries.position, list_entries.note, list_entries.added_at,
+			movies.title, movies.year, movies.runtime, movies.genres, movies.version, ratings.rating, ratings.rating_count
+		FROM list_entries
+		INNER JOIN movies ON movies.id = list_entries.movie_id
+		LEFT JOIN LATERAL (`+ratingsQuery+`) ratings ON true
+		WHERE list_entries.list_id = $1
+		AND movies.deleted_at IS NULL
+	) entries
+	ORDER BY %s %s, position ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, listID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*ListEntry{}
+
+	for rows.Next() {
+		entry := ListEntry{ListID: listID, Movie: &Movie{}}
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.MovieID,
+			&entry.Position,
+			&entry.Note,
+			&entry.AddedAt,
+			&entry.Movie.Title,
+			&entry.Movie.Year,
+			&entry.Movie.Runtime,
+			pq.Array(&entry.Movie.Genres),
+			&entry.Movie.Version,
+			&entry.Movie.AverageRating,
+			&entry.Movie.RatingCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entry.Movie.ID = entry.MovieID
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
+
+// AddEntry puts the movie on the list at entry.Position, moving the entries
+// from there on down by one, or at the end when Position is 0 or past it.
+func (m ListModel) AddEntry(entry *ListEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockList(ctx, tx, entry.ListID)
+	if err != nil {
+		return err
+	}
+
+	if entry.Position == 0 || entry.Position > count+1 {
+		entry.Position = count + 1
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE list_entries
+	SET position = position + 1
+	WHERE list_id = $1 AND position >= $2`, entry.ListID, entry.Position)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	INSERT INTO list_entries (list_id, movie_id, position, note)
+	VALUES ($1, $2, $3, $4)
+	RETURNING added_at`
+
+	args := []interface{}{entry.ListID, entry.MovieID, entry.Position, entry.Note}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&entry.AddedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "list_entries_pkey"`:
+			return ErrDuplicateListEntry
+		default:
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UpdateEntry saves the note of the entry and moves it to entry.Position,
+// shifting the entries in between, or to the end when Position is past it.
+func (m ListModel) UpdateEntry(entry *ListEntry) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	count, err := lockList(ctx, tx, entry.ListID)
+	if err != nil {
+		return err
+	}
+
+	var current int32
+	err = tx.QueryRowContext(ctx, `
+	SELECT position FROM list_entries
+	WHERE list_id = $1 AND movie_id = $2`, entry.ListID, entry.MovieID).Scan(&current)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	if entry.Position == 0 {
+		entry.Position = current
+	}
+	if entry.Position > count {
+		entry.Position = count
+	}
+
+	switch {
+	case entry.Position < current:
+		_, err = tx.ExecContext(ctx, `
+		UPDATE list_entries
+		SET position = position + 1
+		WHERE list_id = $1 AND position >= $2 AND position < $3`, entry.ListID, entry.Position, current)
+	case entry.Position > current:
+		_, err = tx.ExecContext(ctx, `
+		UPDATE list_entries
+		SET position = position - 1
+		WHERE list_id = $1 AND position > $2 AND position <= $3`, entry.ListID, current, entry.Position)
+	}
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE list_entries
+	SET position = $1, note = $2
+	WHERE list_id = $3 AND movie_id = $4
+	RETURNING added_at`
+
+	args := []interface{}{entry.Position, entry.Note, entry.ListID, entry.MovieID}
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&entry.AddedAt)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m ListModel) GetEntry(listID, movieID int64) (*ListEntry, error) {
+	query := `
+	SELECT movie_id, position, note, added_at
+	FROM list_entries
+	WHERE list_id = $1 AND movie_id = $2`
+
+	entry := ListEntry{ListID: listID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, listID, movieID).Scan(&entry.MovieID, &entry.Position, &entry.Note, &entry.AddedAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+func (m ListModel) RemoveEntry(listID, movieID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = lockList(ctx, tx, listID)
+	if err != nil {
+		return err
+	}
+
+	var position int32
+	err = tx.QueryRowContext(ctx, `
+	DELETE FROM list_entries
+	WHERE list_id = $1 AND movie_id = $2
+	RETURNING position`, listID, movieID).Scan(&position)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+	UPDATE list_entries
+	SET position = position - 1
+	WHERE list_id = $1 AND position > $2`, listID, position)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// lockList locks the list against concurrent changes to its entries for the
+// rest of the transaction and returns the number of entries on it.
+func lockList(ctx context.Context, tx *sql.Tx, listID int64) (int32, error) {
+	_, err := tx.ExecContext(ctx, `SELECT id FROM lists WHERE id = $1 FOR UPDATE`, listID)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int32
+	err = tx.QueryRowContext(ctx, `SELECT count(*) FROM list_entries WHERE list_id = $1`, listID).Scan(&count)
+	return count, err
+}
+
+// removeFromLists takes the movie off every list it is on and closes the gaps
+// this leaves in their positions, within the transaction deleting the movie.
+func removeFromLists(ctx context.Context, tx *sql.Tx, movieID int64) error {
+	rows, err := tx.QueryContext(ctx, `
+	DELETE FROM list_entries
+	WHERE movie_id = $1
+	RETURNING list_id`, movieID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	listIDs := []int64{}
+
+	for rows.Next() {
+		var listID int64
+		if err := rows.Scan(&listID); err != nil {
+			return err
+		}
+		listIDs = append(listIDs, listID)
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if len(listIDs) == 0 {
+		return nil
+	}
+
+	query := `
+	UPDATE list_entries
+	SET position = renumbered.position
+	FROM (
+		SELECT list_id, movie_id, row_number() OVER (PARTITION BY list_id ORDER BY position) AS position
+		FROM list_entries
+		WHERE list_id = ANY($1)
+	) renumbered
+	WHERE list_entries.list_id = renumbered.list_id
+	AND list_entries.movie_id = renumbered.movie_id
+	AND list_entries.position <> renumbered.position`
+
+	_, err = tx.ExecContext(ctx, query, pq.Array(listIDs))
+	return err
+}
@@ -0,0 +1,264 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+)
+
+const recoveryCodeCount = 10
+
+var (
+	ErrMFAEnabled    = errors.New("mfa already enabled")
+	ErrMFANotEnabled = errors.New("mfa not enabled")
+	ErrMFACodeUsed   = errors.New("mfa code already used")
+)
+
+type TOTP struct {
+	UserID    int64
+	Secret    []byte
+	Confirmed bool
+	LastStep  int64
+}
+
+func ValidateMFACode(v *validator.Validator, code string) {
+	v.Check(code != "", "code", "must be provided")
+	v.Check(len(code) <= 20, "code", "must not be more than 20 bytes long")
+}
+
+// GenerateRecoveryCodes returns a set of one-time recovery codes in the form
+// XXXXX-XXXXX.
+func GenerateRecoveryCodes() ([]string, error) {
+	codes := make([]string, recoveryCodeCount)
+
+	for i := range codes {
+		randomBytes := make([]byte, 7)
+
+		_, err := rand.Read(randomBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		code := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes)[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+
+	return codes, nil
+}
+
+// hashRecoveryCode hashes a recovery code ignoring case and dashes, so that
+// codes can be typed back however they were written down.
+func hashRecoveryCode(code string) []byte {
+	code = strings.ToUpper(strings.ReplaceAll(code, "-", ""))
+	hash := sha256.Sum256([]byte(code))
+	return hash[:]
+}
+
+type MFAModel struct {
+	DB    *sql.DB
+	Cache *AuthCache
+}
+
+// Enrol stores a new, unconfirmed TOTP secret for the user, replacing any
+// earlier unconfirmed one.
+func (m MFAModel) Enrol(userID int64, secret []byte) error {
+	query := `
+	INSERT INTO users_totp (user_id, secret)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET secret = EXCLUDED.secret, created_at = NOW()
+	WHERE users_totp.confirmed = false`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, secret)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMFAEnabled
+	}
+	return nil
+}
+
+func (m MFAModel) Get(userID int64) (*TOTP, error) {
+	query := `
+	SELECT user_id, secret, confirmed, last_step
+	FROM users_totp
+	WHERE user_id = $1`
+
+	var totp TOTP
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID).Scan(&totp.UserID, &totp.Secret, &totp.Confirmed, &totp.LastStep)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &totp, nil
+}
+
+// Confirm enables two-factor authentication for the user once they have
+// proven they can generate codes for step, replacing their recovery codes.
+func (m MFAModel) Confirm(userID int64, step int64, recoveryCodes []string) error {
+	query := `
+	UPDATE users_totp
+	SET confirmed = true, last_step = $2
+	WHERE user_id = $1 AND confirmed = false`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMFAEnabled
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM users_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range recoveryCodes {
+		_, err = tx.ExecContext(ctx, "INSERT INTO users_recovery_codes (user_id, hash) VALUES ($1, $2)", userID, hashRecoveryCode(code))
+		if err != nil {
+			return err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidateTokens(userID)
+	return nil
+}
+
+// UseStep records that the code for step has been used, failing with
+// ErrMFACodeUsed if it, or a later one, already has been.
+func (m MFAModel) UseStep(userID int64, step int64) error {
+	query := `
+	UPDATE users_totp
+	SET last_step = $2
+	WHERE user_id = $1 AND confirmed = true AND last_step < $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMFACodeUsed
+	}
+	return nil
+}
+
+// UseRecoveryCode consumes one of the user's recovery codes, failing with
+// ErrRecordNotFound if it does not exist or has already been used.
+func (m MFAModel) UseRecoveryCode(userID int64, code string) error {
+	query := `
+	DELETE FROM users_recovery_codes
+	WHERE user_id = $1 AND hash = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, hashRecoveryCode(code))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// Delete disables two-factor authentication for the user.
+func (m MFAModel) Delete(userID int64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM users_totp WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMFANotEnabled
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM users_recovery_codes WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidateTokens(userID)
+	return nil
+}
@@ -0,0 +1,51 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+type MockAPIKeyModel struct{}
+
+func (m MockAPIKeyModel) Insert(key *data.APIKey) error {
+	key.ID = 3
+	key.CreatedAt = time.Now()
+	return nil
+}
+
+func (m MockAPIKeyModel) GetForKey(keyPlaintext string) (*data.User, *data.APIKey, error) {
+	switch keyPlaintext {
+	case "AKR34GKUHNDUSJ3QRUT6IKWKRIAAAAAA":
+		return MockUser, &data.APIKey{ID: 1, UserID: 1, Name: "ingest", Permissions: data.Permissions{"movies:read"}}, nil
+	case "AKW34GKUHNDUSJ3QRUT6IKWKRIAAAAAA":
+		return MockUser, &data.APIKey{ID: 2, UserID: 1, Name: "ingest-write", Permissions: data.Permissions{"movies:read", "movies:write"}}, nil
+	default:
+		return nil, nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockAPIKeyModel) GetAllForUser(userID int64) ([]*data.APIKey, error) {
+	switch userID {
+	case 1:
+		return []*data.APIKey{
+			{ID: 1, UserID: 1, Name: "ingest", Prefix: "AKR34GKU", Permissions: data.Permissions{"movies:read"}, CreatedAt: time.Now()},
+			{ID: 2, UserID: 1, Name: "ingest-write", Prefix: "AKW34GKU", Permissions: data.Permissions{"movies:read", "movies:write"}, CreatedAt: time.Now()},
+		}, nil
+	default:
+		return []*data.APIKey{}, nil
+	}
+}
+
+func (m MockAPIKeyModel) Delete(userID, id int64) error {
+	switch {
+	case userID == 1 && (id == 1 || id == 2):
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockAPIKeyModel) DeleteAllForUser(userID int64) error {
+	return nil
+}
@@ -0,0 +1,36 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+type MockAttemptModel struct{}
+
+func (m MockAttemptModel) Get(kind, key string) (*data.LoginAttempts, error) {
+	attempts := data.LoginAttempts{Kind: kind, Key: key}
+
+	switch key {
+	case "locked@gmail.com", "203.0.113.9":
+		attempts.LockedUntil = time.Now().Add(10 * time.Minute)
+	case "slow@gmail.com":
+		attempts.Failures = 5
+		attempts.LastFailureAt = time.Now()
+	}
+
+	return &attempts, nil
+}
+
+func (m MockAttemptModel) RecordFailure(kind, key string, threshold int, window time.Duration) (*data.LoginAttempts, error) {
+	attempts := data.LoginAttempts{Kind: kind, Key: key, Failures: 1, LastFailureAt: time.Now()}
+	return &attempts, nil
+}
+
+func (m MockAttemptModel) Reset(kind, key string) error {
+	return nil
+}
+
+func (m MockAttemptModel) DeleteStale(window time.Duration) (int64, error) {
+	return 0, nil
+}
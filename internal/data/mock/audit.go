@@ -0,0 +1,50 @@
+package mock
+
+import (
+	"sync"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+// MockAuditModel keeps the events written to it, so that tests can check
+// what a handler recorded.
+type MockAuditModel struct {
+	mu     sync.Mutex
+	Events []*data.AuditEvent
+}
+
+func (m *MockAuditModel) Insert(event *data.AuditEvent) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	event.ID = int64(len(m.Events) + 1)
+	event.CreatedAt = time.Now()
+	m.Events = append(m.Events, event)
+	return nil
+}
+
+func (m *MockAuditModel) GetAll(filter data.AuditFilter, filters data.Filters) ([]*data.AuditEvent, data.Metadata, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	events := []*data.AuditEvent{}
+	for _, event := range m.Events {
+		switch {
+		case filter.ActorID != nil && (event.ActorID == nil || *event.ActorID != *filter.ActorID):
+		case filter.Action != "" && event.Action != filter.Action:
+		case filter.TargetType != "" && event.TargetType != filter.TargetType:
+		case filter.TargetID != "" && event.TargetID != filter.TargetID:
+		case filter.Since != nil && event.CreatedAt.Before(*filter.Since):
+		case filter.Until != nil && !event.CreatedAt.Before(*filter.Until):
+		default:
+			events = append(events, event)
+		}
+	}
+
+	metadata := data.Metadata{}
+	if len(events) > 0 {
+		metadata = data.Metadata{CurrentPage: 1, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: len(events)}
+	}
+	return events, metadata, nil
+}
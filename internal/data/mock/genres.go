@@ -0,0 +1,83 @@
+package mock
+
+import (
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+var mockGenres = []*data.Genre{
+	{ID: 1, Slug: "action", Name: "Action", Aliases: []string{}, MovieCount: 0, Version: 1},
+	{ID: 2, Slug: "comedy", Name: "Comedy", Aliases: []string{}, MovieCount: 1, Version: 1},
+	{ID: 3, Slug: "drama", Name: "Drama", Aliases: []string{}, MovieCount: 1, Version: 1},
+	{ID: 4, Slug: "horror", Name: "Horror", Aliases: []string{}, MovieCount: 0, Version: 1},
+	{ID: 5, Slug: "romance", Name: "Romance", Aliases: []string{}, MovieCount: 0, Version: 1},
+	{ID: 6, Slug: "science-fiction", Name: "Science Fiction", Aliases: []string{"sci-fi", "scifi"}, MovieCount: 0, Version: 1},
+	{ID: 7, Slug: "thriller", Name: "Thriller", Aliases: []string{}, MovieCount: 0, Version: 1},
+	{ID: 8, Slug: "sf", Name: "SF", Aliases: []string{}, MovieCount: 0, Version: 1},
+	{ID: 9, Slug: "fantasy", Name: "Fantasy", Aliases: []string{}, MovieCount: 0, Version: 1},
+}
+
+type MockGenreModel struct{}
+
+func (m MockGenreModel) Index() (data.GenreIndex, error) {
+	index := make(data.GenreIndex)
+	for _, genre := range mockGenres {
+		index[genre.Slug] = genre.Slug
+		for _, alias := range genre.Aliases {
+			index[alias] = genre.Slug
+		}
+	}
+	return index, nil
+}
+
+func (m MockGenreModel) GetAll() ([]*data.Genre, error) {
+	return mockGenres, nil
+}
+
+func (m MockGenreModel) Get(id int64) (*data.Genre, error) {
+	for _, genre := range mockGenres {
+		if genre.ID == id {
+			g := *genre
+			return &g, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func (m MockGenreModel) Insert(genre *data.Genre) error {
+	if err := m.checkNames(0, genre); err != nil {
+		return err
+	}
+	genre.ID = 10
+	genre.Version = 1
+	return nil
+}
+
+func (m MockGenreModel) Update(genre *data.Genre, userID int64) error {
+	if err := m.checkNames(genre.ID, genre); err != nil {
+		return err
+	}
+	genre.Version++
+	return nil
+}
+
+func (m MockGenreModel) Merge(source, target *data.Genre, userID int64) error {
+	target.Aliases = append(target.Aliases, source.Slug)
+	target.Aliases = append(target.Aliases, source.Aliases...)
+	target.MovieCount += source.MovieCount
+	target.Version++
+	return nil
+}
+
+func (m MockGenreModel) checkNames(id int64, genre *data.Genre) error {
+	index, _ := m.Index()
+	for _, name := range append([]string{genre.Slug}, genre.Aliases...) {
+		if slug, ok := index[name]; ok {
+			for _, g := range mockGenres {
+				if g.Slug == slug && g.ID != id {
+					return data.ErrDuplicateGenre
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+// User 1 owns the public list 1, which has movie 1 on it, and the private
+// list 2, which is empty.
+var mockLists = []*data.List{
+	{
+		ID:          1,
+		UserID:      1,
+		CreatedAt:   time.Now(),
+		Name:        "Best of 2003",
+		Description: "The movies of 2003 worth watching again.",
+		Public:      true,
+		MovieCount:  1,
+		Version:     1,
+	},
+	{
+		ID:        2,
+		UserID:    1,
+		CreatedAt: time.Now(),
+		Name:      "Guilty pleasures",
+		Version:   1,
+	},
+}
+
+var mockListEntry = &data.ListEntry{
+	ListID:   1,
+	MovieID:  1,
+	Position: 1,
+	Note:     "Still holds up.",
+	AddedAt:  time.Now(),
+}
+
+type MockListModel struct{}
+
+func (m MockListModel) Insert(list *data.List) error {
+	list.ID = 3
+	list.CreatedAt = time.Now()
+	list.Version = 1
+	return nil
+}
+
+func (m MockListModel) Get(id int64) (*data.List, error) {
+	for _, list := range mockLists {
+		if list.ID == id {
+			l := *list
+			return &l, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func (m MockListModel) Update(list *data.List) error {
+	list.Version++
+	return nil
+}
+
+func (m MockListModel) Delete(id int64) error {
+	for _, list := range mockLists {
+		if list.ID == id {
+			return nil
+		}
+	}
+	return data.ErrRecordNotFound
+}
+
+func (m MockListModel) GetAll(viewerID, ownerID int64, name string, filters data.Filters) ([]*data.List, data.Metadata, error) {
+	lists := []*data.List{}
+	for _, list := range mockLists {
+		if (list.Public || list.UserID == viewerID) && (ownerID == 0 || list.UserID == ownerID) {
+			l := *list
+			lists = append(lists, &l)
+		}
+	}
+	metadata := data.Metadata{}
+	return lists, metadata, nil
+}
+
+func (m MockListModel) GetEntries(listID int64, filters data.Filters) ([]*data.ListEntry, data.Metadata, error) {
+	entries := []*data.ListEntry{}
+	if listID == mockListEntry.ListID {
+		entry := *mockListEntry
+		movie := *mockMovie
+		entry.Movie = &movie
+		entries = append(entries, &entry)
+	}
+	metadata := data.Metadata{}
+	return entries, metadata, nil
+}
+
+func (m MockListModel) GetEntry(listID, movieID int64) (*data.ListEntry, error) {
+	if listID == mockListEntry.ListID && movieID == mockListEntry.MovieID {
+		entry := *mockListEntry
+		return &entry, nil
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func (m MockListModel) AddEntry(entry *data.ListEntry) error {
+	if entry.ListID == mockListEntry.ListID && entry.MovieID == mockListEntry.MovieID {
+		return data.ErrDuplicateListEntry
+	}
+	if entry.Position == 0 {
+		entry.Position = 1
+	}
+	entry.AddedAt = time.Now()
+	return nil
+}
+
+func (m MockListModel) UpdateEntry(entry *data.ListEntry) error {
+	if entry.ListID != mockListEntry.ListID || entry.MovieID != mockListEntry.MovieID {
+		return data.ErrRecordNotFound
+	}
+	// The list only has the one entry, so it cannot move.
+	entry.Position = 1
+	return nil
+}
+
+func (m MockListModel) RemoveEntry(listID, movieID int64) error {
+	if listID == mockListEntry.ListID && movieID == mockListEntry.MovieID {
+		return nil
+	}
+	return data.ErrRecordNotFound
+}
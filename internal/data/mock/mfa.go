@@ -0,0 +1,57 @@
+package mock
+
+import (
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+// MockTOTPSecret is the TOTP secret of every mock user with two-factor
+// authentication enabled or being enrolled.
+var MockTOTPSecret = []byte("12345678901234567890")
+
+type MockMFAModel struct{}
+
+func (m MockMFAModel) Enrol(userID int64, secret []byte) error {
+	switch userID {
+	case 5:
+		return data.ErrMFAEnabled
+	default:
+		return nil
+	}
+}
+
+func (m MockMFAModel) Get(userID int64) (*data.TOTP, error) {
+	switch userID {
+	case 3:
+		return &data.TOTP{UserID: 3, Secret: MockTOTPSecret}, nil
+	case 5:
+		return &data.TOTP{UserID: 5, Secret: MockTOTPSecret, Confirmed: true}, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockMFAModel) Confirm(userID int64, step int64, recoveryCodes []string) error {
+	return nil
+}
+
+func (m MockMFAModel) UseStep(userID int64, step int64) error {
+	return nil
+}
+
+func (m MockMFAModel) UseRecoveryCode(userID int64, code string) error {
+	switch code {
+	case "ABCDE-FGHIJ":
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockMFAModel) Delete(userID int64) error {
+	switch userID {
+	case 5:
+		return nil
+	default:
+		return data.ErrMFANotEnabled
+	}
+}
@@ -7,7 +7,19 @@ func NewMockModels() data.Models {
 		Movies:       &MockMovieModel{},
 		Users:        &MockUserModel{},
 		Tokens:       &MockTokenModel{},
+		Revocations:  &MockRevocationModel{},
 		UsersProfile: &MockProfileModel{},
 		Permissions:  &MockPermissionModel{},
+		MFA:          &MockMFAModel{},
+		Attempts:     &MockAttemptModel{},
+		APIKeys:      &MockAPIKeyModel{},
+		OAuth:        &MockOAuthModel{},
+		Genres:       &MockGenreModel{},
+		People:       &MockPeopleModel{},
+		Watchlist:    &MockWatchlistModel{},
+		Lists:        &MockListModel{},
+		Reviews:      &MockReviewModel{},
+		Revisions:    &MockRevisionModel{},
+		Audit:        &MockAuditModel{},
 	}
 }
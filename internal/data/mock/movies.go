@@ -1,25 +1,42 @@
 package mock
 
 import (
+	"strings"
 	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/data"
 )
 
 var mockMovie = &data.Movie{
-	ID:        1,
+	ID:            1,
+	UserID:        1,
+	CreatedAt:     time.Now(),
+	Title:         "Test Movie",
+	Year:          2003,
+	Runtime:       2000,
+	Genres:        []string{"Comedy", "Drama"},
+	Version:       1,
+	AverageRating: 7.5,
+	RatingCount:   2,
+}
+
+var deletedAt = time.Now()
+
+var mockDeletedMovie = &data.Movie{
+	ID:        3,
 	UserID:    1,
 	CreatedAt: time.Now(),
-	Title:     "Test Movie",
-	Year:      2003,
-	Runtime:   2000,
-	Genres:    []string{"Comedy", "Drama"},
+	Title:     "Deleted Movie",
+	Year:      1999,
+	Runtime:   120,
+	Genres:    []string{"Thriller"},
 	Version:   1,
+	DeletedAt: &deletedAt,
 }
 
 type MockMovieModel struct{}
 
-func (m MockMovieModel) Insert(movie *data.Movie) error {
+func (m MockMovieModel) Insert(movie *data.Movie, userID int64) error {
 	movie.Version = 1
 	movie.ID = 1
 	return nil
@@ -28,13 +45,14 @@ func (m MockMovieModel) Insert(movie *data.Movie) error {
 func (m MockMovieModel) Get(id int64) (*data.Movie, error) {
 	switch id {
 	case 1:
-		return mockMovie, nil
+		movie := *mockMovie
+		return &movie, nil
 	default:
 		return nil, data.ErrRecordNotFound
 	}
 }
 
-func (m MockMovieModel) Update(movie *data.Movie) error {
+func (m MockMovieModel) Update(movie *data.Movie, userID int64) error {
 	switch movie.ID {
 	case 1:
 		return nil
@@ -43,17 +61,76 @@ func (m MockMovieModel) Update(movie *data.Movie) error {
 	}
 }
 
-func (m MockMovieModel) Delete(id int64) error {
-	switch id {
-	case 1:
+func (m MockMovieModel) Delete(id int64, version int32, userID int64) error {
+	switch {
+	case id == 1 && version == mockMovie.Version:
 		return nil
+	case id == 1:
+		return data.ErrEditConflict
 	default:
 		return data.ErrRecordNotFound
 	}
 }
 
-func (m MockMovieModel) GetAll(title string, genres []string, filters data.Filters) ([]*data.Movie, data.Metadata, error) {
+func (m MockMovieModel) GetAll(filter data.MovieFilter, filters data.Filters) ([]*data.Movie, data.Metadata, error) {
+	movies := []*data.Movie{}
+	metadata := data.Metadata{}
+	if filters.Cursor != nil {
+		movies = append(movies, mockMovie)
+		metadata.PageSize = filters.PageSize
+		metadata.Next = &data.Cursor{Sort: filters.Sort, Value: "1", ID: 1}
+	}
+	return movies, metadata, nil
+}
+
+func (m MockMovieModel) GetDeleted(id int64) (*data.Movie, error) {
+	switch id {
+	case 3:
+		movie := *mockDeletedMovie
+		return &movie, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockMovieModel) GetAllDeleted(userID int64, filters data.Filters) ([]*data.Movie, data.Metadata, error) {
 	movies := []*data.Movie{}
+	if userID == 0 || userID == mockDeletedMovie.UserID {
+		movies = append(movies, mockDeletedMovie)
+	}
 	metadata := data.Metadata{}
 	return movies, metadata, nil
 }
+
+func (m MockMovieModel) GetAllForUser(userID int64) ([]*data.Movie, error) {
+	movies := []*data.Movie{}
+	for _, movie := range []*data.Movie{mockMovie, mockDeletedMovie} {
+		if movie.UserID == userID {
+			movies = append(movies, movie)
+		}
+	}
+	return movies, nil
+}
+
+func (m MockMovieModel) Restore(movie *data.Movie, userID int64) error {
+	switch movie.ID {
+	case 3:
+		movie.Version++
+		movie.DeletedAt = nil
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockMovieModel) Purge(retention time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m MockMovieModel) Suggest(q string, limit int) ([]*data.MovieSuggestion, error) {
+	suggestions := []*data.MovieSuggestion{}
+	if strings.HasPrefix(strings.ToLower(mockMovie.Title), strings.ToLower(q)) {
+		suggestions = append(suggestions, &data.MovieSuggestion{ID: mockMovie.ID, Title: mockMovie.Title, Year: mockMovie.Year})
+	}
+	return suggestions, nil
+}
@@ -0,0 +1,139 @@
+package mock
+
+import (
+	"crypto/sha256"
+	"net/http"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+// MockCodeVerifier and MockCodeChallenge are the PKCE example from RFC 7636.
+const (
+	MockClientSecret  = "CLIENTSECRET34GKUHNDUSJ3QRUT6IKW"
+	MockCodeVerifier  = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+	MockCodeChallenge = "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM"
+	MockRedirectURI   = "https://app.example.com/callback"
+)
+
+func mockSecretHash() []byte {
+	hash := sha256.Sum256([]byte(MockClientSecret))
+	return hash[:]
+}
+
+var (
+	mockPublicClient = &data.OAuthClient{
+		ID:           1,
+		ClientID:     "PUBLIC34GKUHNDUSJ3QRUT6IKW",
+		Name:         "Movie Night",
+		RedirectURIs: []string{MockRedirectURI},
+		Scopes:       data.Permissions{"movies:read"},
+		UserID:       1,
+		CreatedAt:    time.Now(),
+	}
+	mockConfidentialClient = &data.OAuthClient{
+		ID:           2,
+		ClientID:     "CONFID34GKUHNDUSJ3QRUT6IKW",
+		SecretHash:   mockSecretHash(),
+		Name:         "Catalogue Sync",
+		RedirectURIs: []string{MockRedirectURI},
+		Scopes:       data.Permissions{"movies:read", "movies:write"},
+		Confidential: true,
+		UserID:       1,
+		CreatedAt:    time.Now(),
+	}
+)
+
+type MockOAuthModel struct{}
+
+func (m MockOAuthModel) InsertClient(client *data.OAuthClient) error {
+	client.ID = 3
+	client.CreatedAt = time.Now()
+	return nil
+}
+
+func (m MockOAuthModel) GetClient(clientID string) (*data.OAuthClient, error) {
+	switch clientID {
+	case mockPublicClient.ClientID:
+		return mockPublicClient, nil
+	case mockConfidentialClient.ClientID:
+		return mockConfidentialClient, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockOAuthModel) GetAllClients() ([]*data.OAuthClient, error) {
+	return []*data.OAuthClient{mockPublicClient, mockConfidentialClient}, nil
+}
+
+func (m MockOAuthModel) DeleteClient(id int64) error {
+	switch id {
+	case 1, 2:
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockOAuthModel) NewCode(code *data.OAuthCode, ttl time.Duration, r *http.Request) (*data.Token, error) {
+	token := data.Token{Plaintext: "CODE4GKUHNDUSJ3QRUT6IKWKRI", Scope: data.ScopeOAuthCode, Expiry: time.Now().Add(ttl)}
+	return &token, nil
+}
+
+func (m MockOAuthModel) ExchangeCode(codePlaintext string, clientID int64) (*data.OAuthCode, error) {
+	switch {
+	case codePlaintext == "CODE4GKUHNDUSJ3QRUT6IKWKRI" && clientID == 1:
+		return &data.OAuthCode{
+			UserID:        1,
+			ClientID:      1,
+			Permissions:   data.Permissions{"movies:read"},
+			RedirectURI:   MockRedirectURI,
+			CodeChallenge: MockCodeChallenge,
+		}, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockOAuthModel) NewTokens(userID, clientID int64, permissions data.Permissions, accessTTL, refreshTTL time.Duration, r *http.Request) (*data.Token, *data.Token, error) {
+	access := data.Token{Plaintext: "OAT34GKUHNDUSJ3QRUT6IKWKRI", Scope: data.ScopeOAuthAccess, ClientID: clientID, Permissions: permissions, Expiry: time.Now().Add(accessTTL)}
+	if refreshTTL == 0 {
+		return &access, nil, nil
+	}
+	refresh := data.Token{Plaintext: "ORF34GKUHNDUSJ3QRUT6IKWKRI", Scope: data.ScopeOAuthRefresh, ClientID: clientID, Permissions: permissions, Expiry: time.Now().Add(refreshTTL)}
+	return &access, &refresh, nil
+}
+
+func (m MockOAuthModel) Rotate(refreshPlaintext string, clientID int64, accessTTL, refreshTTL time.Duration, r *http.Request) (*data.Token, *data.Token, error) {
+	switch {
+	case refreshPlaintext == "ORF34GKUHNDUSJ3QRUT6IKWKRI" && clientID == 1:
+		return m.NewTokens(1, clientID, data.Permissions{"movies:read"}, accessTTL, refreshTTL, r)
+	case refreshPlaintext == "ORF34GKUHNDUSJ3QRUT6IKWKRU" && clientID == 1:
+		return nil, nil, data.ErrTokenReused
+	default:
+		return nil, nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockOAuthModel) GetForAccessToken(tokenPlaintext string) (*data.User, *data.Token, error) {
+	switch tokenPlaintext {
+	case "OAT34GKUHNDUSJ3QRUT6IKWKRI":
+		return MockUser, &data.Token{Scope: data.ScopeOAuthAccess, UserID: 1, ClientID: 1, Permissions: data.Permissions{"movies:read"}, Expiry: time.Now().Add(time.Hour)}, nil
+	default:
+		return nil, nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockOAuthModel) Introspect(tokenPlaintext string, clientID int64) (*data.User, *data.Token, error) {
+	switch {
+	case tokenPlaintext == "OAT34GKUHNDUSJ3QRUT6IKWKRI" && clientID == 1:
+		return m.GetForAccessToken(tokenPlaintext)
+	default:
+		return nil, nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockOAuthModel) Revoke(tokenPlaintext string, clientID int64) error {
+	return nil
+}
@@ -0,0 +1,123 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+var mockBirthDate = data.Date(time.Date(1970, time.July, 30, 0, 0, 0, 0, time.UTC))
+
+var mockPerson = &data.Person{
+	ID:        1,
+	CreatedAt: time.Now(),
+	Name:      "Christopher Nolan",
+	BirthDate: &mockBirthDate,
+	Bio:       "Test Bio",
+	Version:   1,
+}
+
+var mockActor = &data.Person{
+	ID:        2,
+	CreatedAt: time.Now(),
+	Name:      "Test Actor",
+	Version:   1,
+}
+
+var mockCredits = []*data.Credit{
+	{ID: 1, MovieID: 1, PersonID: 1, Role: data.CreditDirector},
+	{ID: 2, MovieID: 1, PersonID: 2, Role: data.CreditActor, Character: "Test Character"},
+}
+
+type MockPeopleModel struct{}
+
+func (m MockPeopleModel) Insert(person *data.Person) error {
+	person.ID = 3
+	person.Version = 1
+	return nil
+}
+
+func (m MockPeopleModel) Get(id int64) (*data.Person, error) {
+	switch id {
+	case 1:
+		person := *mockPerson
+		return &person, nil
+	case 2:
+		person := *mockActor
+		return &person, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockPeopleModel) Update(person *data.Person) error {
+	switch person.ID {
+	case 1, 2:
+		person.Version++
+		return nil
+	default:
+		return data.ErrEditConflict
+	}
+}
+
+func (m MockPeopleModel) Delete(id int64) error {
+	switch id {
+	case 1, 2:
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockPeopleModel) GetAll(name string, filters data.Filters) ([]*data.Person, data.Metadata, error) {
+	people := []*data.Person{mockPerson, mockActor}
+	metadata := data.Metadata{}
+	return people, metadata, nil
+}
+
+func (m MockPeopleModel) InsertCredit(credit *data.Credit) error {
+	for _, c := range mockCredits {
+		if c.MovieID == credit.MovieID && c.PersonID == credit.PersonID && c.Role == credit.Role && c.Character == credit.Character {
+			return data.ErrDuplicateCredit
+		}
+	}
+	if credit.PersonID != 1 && credit.PersonID != 2 {
+		return data.ErrUnknownPerson
+	}
+	credit.ID = 3
+	return nil
+}
+
+func (m MockPeopleModel) DeleteCredit(movieID, id int64) error {
+	for _, c := range mockCredits {
+		if c.MovieID == movieID && c.ID == id {
+			return nil
+		}
+	}
+	return data.ErrRecordNotFound
+}
+
+func (m MockPeopleModel) GetCreditsForMovie(movieID int64) ([]*data.Credit, error) {
+	credits := []*data.Credit{}
+	for _, c := range mockCredits {
+		if c.MovieID == movieID {
+			credit := *c
+			credit.Person, _ = m.Get(c.PersonID)
+			credits = append(credits, &credit)
+		}
+	}
+	return credits, nil
+}
+
+func (m MockPeopleModel) GetFilmography(personID int64) ([]*data.Credit, error) {
+	credits := []*data.Credit{}
+	for _, c := range mockCredits {
+		if c.PersonID == personID {
+			credit := *c
+			movie := *mockMovie
+			credit.Movie = &movie
+			credits = append(credits, &credit)
+		}
+	}
+	return credits, nil
+}
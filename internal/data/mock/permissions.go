@@ -5,7 +5,11 @@ import "github.com/IfedayoAwe/greenlight/internal/data"
 var mockPermissions1 = &data.Permissions{"movies:read", "movies:write"}
 var mockPermissions2 = &data.Permissions{"movies:read"}
 
-type MockPermissionModel struct{}
+type MockPermissionModel struct {
+	// RequiringMFA lists the permissions that are only granted to users with
+	// two-factor authentication enabled.
+	RequiringMFA data.Permissions
+}
 
 func (m MockPermissionModel) GetAllForUser(userID int64) (data.Permissions, error) {
 	switch userID {
@@ -25,3 +29,122 @@ func (m MockPermissionModel) AddForUser(userID int64, codes ...string) error {
 		return nil
 	}
 }
+
+var mockRoles = []*data.Role{
+	{ID: 1, Name: "viewer", Permissions: data.Permissions{"movies:read"}, Version: 1},
+	{ID: 2, Name: "contributor", Permissions: data.Permissions{"movies:read", "movies:write"}, Version: 1},
+}
+
+func knownPermissions(codes ...string) bool {
+	for _, code := range codes {
+		if code != "movies:read" && code != "movies:write" {
+			return false
+		}
+	}
+	return true
+}
+
+func (m MockPermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	switch userID {
+	case 1, 4:
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockPermissionModel) GetAllRoles() ([]*data.Role, error) {
+	return mockRoles, nil
+}
+
+func (m MockPermissionModel) GetRole(id int64) (*data.Role, error) {
+	for _, role := range mockRoles {
+		if role.ID == id {
+			r := *role
+			return &r, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func (m MockPermissionModel) InsertRole(role *data.Role) error {
+	for _, r := range mockRoles {
+		if r.Name == role.Name {
+			return data.ErrDuplicateRole
+		}
+	}
+	if !knownPermissions(role.Permissions...) {
+		return data.ErrUnknownPermission
+	}
+	role.ID = 3
+	role.Version = 1
+	return nil
+}
+
+func (m MockPermissionModel) UpdateRole(role *data.Role) error {
+	if !knownPermissions(role.Permissions...) {
+		return data.ErrUnknownPermission
+	}
+	role.Version++
+	return nil
+}
+
+func (m MockPermissionModel) GetRolesForUser(userID int64) ([]string, error) {
+	switch userID {
+	case 1, 4:
+		return []string{"contributor"}, nil
+	default:
+		return []string{"viewer"}, nil
+	}
+}
+
+func (m MockPermissionModel) AddRolesForUser(userID int64, names ...string) error {
+	for _, name := range names {
+		if name != "viewer" && name != "contributor" {
+			return data.ErrUnknownRole
+		}
+	}
+	return nil
+}
+
+func (m MockPermissionModel) RemoveRolesForUser(userID int64, names ...string) error {
+	switch userID {
+	case 1, 4:
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockPermissionModel) GetRequiringMFA() (data.Permissions, error) {
+	return m.RequiringMFA, nil
+}
+
+func (m MockPermissionModel) SetRequiresMFA(code string, required bool) error {
+	if !knownPermissions(code) {
+		return data.ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m MockPermissionModel) GetUsersWithPermission(code string) ([]int64, error) {
+	switch code {
+	case "movies:read":
+		return []int64{1, 2, 3, 4, 5}, nil
+	case "movies:write":
+		return []int64{1, 4}, nil
+	default:
+		return []int64{}, nil
+	}
+}
+
+func (m MockPermissionModel) GetUsersWithRole(id int64) ([]int64, error) {
+	switch id {
+	case 1:
+		return []int64{2, 3, 5}, nil
+	case 2:
+		return []int64{1, 4}, nil
+	default:
+		return []int64{}, nil
+	}
+}
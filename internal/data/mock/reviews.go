@@ -0,0 +1,67 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+var mockReview = &data.Review{
+	ID:        1,
+	MovieID:   1,
+	UserID:    1,
+	CreatedAt: time.Now(),
+	Rating:    8,
+	Body:      "Test Review",
+	Version:   1,
+}
+
+type MockReviewModel struct{}
+
+func (m MockReviewModel) Insert(review *data.Review) error {
+	switch review.UserID {
+	case 1:
+		return data.ErrDuplicateReview
+	default:
+		review.ID = 2
+		review.Version = 1
+		return nil
+	}
+}
+
+func (m MockReviewModel) Get(id int64) (*data.Review, error) {
+	switch id {
+	case 1:
+		review := *mockReview
+		return &review, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockReviewModel) Update(review *data.Review) error {
+	switch review.ID {
+	case 1:
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockReviewModel) Delete(id int64) error {
+	switch id {
+	case 1:
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockReviewModel) GetAllForMovie(movieID int64, filters data.Filters) ([]*data.Review, data.Metadata, error) {
+	reviews := []*data.Review{}
+	if movieID == 1 {
+		reviews = append(reviews, mockReview)
+	}
+	metadata := data.Metadata{}
+	return reviews, metadata, nil
+}
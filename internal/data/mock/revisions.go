@@ -0,0 +1,54 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+var mockRevision = &data.MovieRevision{
+	MovieID:   1,
+	Version:   1,
+	UserID:    1,
+	Action:    data.RevisionCreate,
+	CreatedAt: time.Now(),
+	Title:     "Original Movie",
+	Year:      2001,
+	Runtime:   1900,
+	Genres:    []string{"Comedy"},
+}
+
+// mockDeletedRevision belongs to the movie in the trash.
+var mockDeletedRevision = &data.MovieRevision{
+	MovieID:   3,
+	Version:   1,
+	UserID:    1,
+	Action:    data.RevisionCreate,
+	CreatedAt: time.Now(),
+	Title:     "Deleted Movie",
+	Year:      1999,
+	Runtime:   120,
+	Genres:    []string{"Thriller"},
+}
+
+type MockRevisionModel struct{}
+
+func (m MockRevisionModel) Get(movieID int64, version int32) (*data.MovieRevision, error) {
+	switch {
+	case movieID == 1 && version == 1:
+		return mockRevision, nil
+	case movieID == 3 && version == 1:
+		return mockDeletedRevision, nil
+	default:
+		return nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockRevisionModel) GetAllForMovie(movieID int64, filters data.Filters) ([]*data.MovieRevision, data.Metadata, error) {
+	revisions := []*data.MovieRevision{}
+	if movieID == 1 {
+		revisions = append(revisions, mockRevision)
+	}
+	metadata := data.Metadata{}
+	return revisions, metadata, nil
+}
@@ -0,0 +1,25 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+type MockRevocationModel struct{}
+
+func (m MockRevocationModel) RevokeToken(jti string, expiry time.Time) error {
+	return nil
+}
+
+func (m MockRevocationModel) RevokeUser(userID int64, at time.Time) error {
+	return nil
+}
+
+func (m MockRevocationModel) GetAll(maxAge time.Duration) (*data.Revocations, error) {
+	return &data.Revocations{Tokens: map[string]time.Time{}, Users: map[int64]time.Time{}}, nil
+}
+
+func (m MockRevocationModel) DeleteExpired(maxAge time.Duration) (int64, error) {
+	return 0, nil
+}
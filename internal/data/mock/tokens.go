@@ -1,12 +1,17 @@
 package mock
 
 import (
+	"crypto/sha256"
 	"net/http"
 	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/data"
 )
 
+// MockPreviousAccessToken is the authentication token that refreshing the
+// session of MockUser revokes.
+const MockPreviousAccessToken = "HTE34GKUHNDUSJ3QRUT6IKWKRP"
+
 type MockTokenModel struct{}
 
 func (m MockTokenModel) Insert(token *data.Token) error {
@@ -19,5 +24,71 @@ func (m MockTokenModel) DeleteAllForUser(scope string, userID int64, userIP *str
 
 func (m MockTokenModel) New(userID int64, ttl time.Duration, scope string, r *http.Request) (*data.Token, error) {
 	token := data.Token{}
+	// Export archives are named after the hash of their download token.
+	if scope == data.ScopeExport {
+		hash := sha256.Sum256([]byte("EXP34GKUHNDUSJ3QRUT6IKWKRI"))
+		token = data.Token{Plaintext: "EXP34GKUHNDUSJ3QRUT6IKWKRI", Hash: hash[:], UserID: userID, Scope: scope, Expiry: time.Now().Add(ttl)}
+	}
 	return &token, nil
 }
+
+func (m MockTokenModel) NewEmailRevert(userID int64, email string, ttl time.Duration, r *http.Request) (*data.Token, error) {
+	return &data.Token{UserID: userID, Scope: data.ScopeEmailRevert, Expiry: time.Now().Add(ttl), Email: email}, nil
+}
+
+func (m MockTokenModel) NewPair(userID int64, deviceName string, accessTTL, refreshTTL time.Duration, r *http.Request) (*data.Token, *data.Token, error) {
+	// Every new pair hashes like the current session of GetSessionsForUser.
+	hash := sha256.Sum256([]byte("HTE34GKUHNDUSJ3QRUT6IKWKRI"))
+	access := data.Token{Hash: hash[:], UserID: userID, Scope: data.ScopeAuthentication, Expiry: time.Now().Add(accessTTL)}
+	refresh := data.Token{UserID: userID, Scope: data.ScopeRefresh, Expiry: time.Now().Add(refreshTTL)}
+	return &access, &refresh, nil
+}
+
+func (m MockTokenModel) Rotate(refreshPlaintext string, accessTTL, refreshTTL time.Duration, r *http.Request) (*data.Token, *data.Token, []*data.Token, error) {
+	// Both refresh tokens belong to a family whose previous authentication
+	// token hashes like MockPreviousAccessToken.
+	previous := sha256.Sum256([]byte(MockPreviousAccessToken))
+	revoked := []*data.Token{{Hash: previous[:], UserID: 1, Scope: data.ScopeAuthentication, Expiry: time.Now().Add(accessTTL)}}
+
+	switch refreshPlaintext {
+	case "RFR34GKUHNDUSJ3QRUT6IKWKRI":
+		access, refresh, err := m.NewPair(1, "", accessTTL, refreshTTL, r)
+		return access, refresh, revoked, err
+	case "RFR34GKUHNDUSJ3QRUT6IKWKRU":
+		return nil, nil, revoked, data.ErrTokenReused
+	default:
+		return nil, nil, nil, data.ErrRecordNotFound
+	}
+}
+
+func (m MockTokenModel) GetSessionsForUser(userID int64) ([]*data.Session, error) {
+	current := sha256.Sum256([]byte("HTE34GKUHNDUSJ3QRUT6IKWKRI"))
+	other := sha256.Sum256([]byte("HTE34GKUHNDUSJ3QRUT6IKWKRO"))
+
+	switch userID {
+	case 1:
+		return []*data.Session{
+			{ID: 1, Hash: current[:], UserIP: "127.0.0.1", UserAgent: "Go-http-client/1.1", Expiry: time.Now().Add(time.Hour)},
+			{ID: 2, Hash: other[:], UserIP: "10.0.0.1", UserAgent: "greenlight-ios/2.1", DeviceName: "Ola's iPhone", Expiry: time.Now().Add(time.Hour)},
+		}, nil
+	default:
+		return []*data.Session{}, nil
+	}
+}
+
+func (m MockTokenModel) DeleteSession(userID, id int64) error {
+	switch {
+	case userID == 1 && (id == 1 || id == 2):
+		return nil
+	default:
+		return data.ErrRecordNotFound
+	}
+}
+
+func (m MockTokenModel) DeleteSessionForToken(userID int64, tokenHash []byte) error {
+	return nil
+}
+
+func (m MockTokenModel) DeleteOtherSessions(userID int64, tokenHash []byte) error {
+	return nil
+}
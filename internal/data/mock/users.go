@@ -1,6 +1,7 @@
 package mock
 
 import (
+	"strings"
 	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/data"
@@ -52,6 +53,19 @@ var (
 		Admin:     false,
 		Version:   1,
 	}
+	MockUser5 = &data.User{
+		ID:         5,
+		Name:       "Tunde Awe",
+		Email:      "tunde@gmail.com",
+		CreatedAt:  time.Now(),
+		Activated:  true,
+		Admin:      false,
+		MFAEnabled: true,
+		Version:    1,
+		Password: data.Password{
+			Hash: pass("1234567890"),
+		},
+	}
 )
 
 var ()
@@ -60,13 +74,52 @@ type MockUserModel struct{}
 
 func (m MockUserModel) Insert(user *data.User) error {
 	switch user.Email {
-	case "olalekanawe99@gmail.com", "ayo@gmail.com", "vicky@gmail.com", "mummy@gmail.com":
+	case "olalekanawe99@gmail.com", "ayo@gmail.com", "vicky@gmail.com", "mummy@gmail.com", "tunde@gmail.com":
 		return data.ErrDuplicateEmail
 	default:
 		return nil
 	}
 }
 
+// Get hands out copies, as the admin handlers change the flags of the user
+// they get back.
+func (m MockUserModel) Get(id int64) (*data.User, error) {
+	for _, user := range mockUsers() {
+		if user.ID == id {
+			user := *user
+			return &user, nil
+		}
+	}
+	return nil, data.ErrRecordNotFound
+}
+
+func mockUsers() []*data.User {
+	return []*data.User{MockUser, MockUser2, MockUser3, MockUser4, MockUser5}
+}
+
+func (m MockUserModel) GetAll(search string, filter data.UserFilter, filters data.Filters) ([]*data.User, data.Metadata, error) {
+	matches := func(flag *bool, value bool) bool {
+		return flag == nil || *flag == value
+	}
+
+	search = strings.ToLower(search)
+	users := []*data.User{}
+	for _, user := range mockUsers() {
+		if !strings.Contains(strings.ToLower(user.Name), search) && !strings.Contains(user.Email, search) {
+			continue
+		}
+		if matches(filter.Activated, user.Activated) && matches(filter.Admin, user.Admin) && matches(filter.Suspended, user.Suspended) {
+			users = append(users, user)
+		}
+	}
+
+	metadata := data.Metadata{}
+	if len(users) > 0 {
+		metadata = data.Metadata{CurrentPage: 1, PageSize: filters.PageSize, FirstPage: 1, LastPage: 1, TotalRecords: len(users)}
+	}
+	return users, metadata, nil
+}
+
 func (m MockUserModel) GetByEmail(email string) (*data.User, error) {
 	switch email {
 	case "olalekanawe99@gmail.com":
@@ -77,6 +130,8 @@ func (m MockUserModel) GetByEmail(email string) (*data.User, error) {
 		return MockUser3, nil
 	case "mummy@gmail.com":
 		return MockUser4, nil
+	case "tunde@gmail.com":
+		return MockUser5, nil
 	default:
 		return nil, data.ErrRecordNotFound
 	}
@@ -101,18 +156,67 @@ func (m MockUserModel) GetForToken(tokenScope, tokenPlaintext string) (*data.Use
 		return MockUser3, nil
 	case "HTE34GKUHNDUSJ3QRUT6IKWKRM":
 		return MockUser4, nil
+	case "HTE34GKUHNDUSJ3QRUT6IKWKRN", "MFA34GKUHNDUSJ3QRUT6IKWKRN":
+		return MockUser5, nil
+	// The email change tokens hand out copies, as the handlers change the
+	// email addresses of the user they get back.
+	case "EML34GKUHNDUSJ3QRUT6IKWKRL":
+		user := *MockUser3
+		user.PendingEmail = "vicky.new@gmail.com"
+		return &user, nil
+	case "EML34GKUHNDUSJ3QRUT6IKWKRM":
+		user := *MockUser4
+		user.PendingEmail = "foo@gmail.com"
+		return &user, nil
+	case "SUS34GKUHNDUSJ3QRUT6IKWKRL":
+		user := *MockUser3
+		user.Suspended = true
+		return &user, nil
+	case "SUS34GKUHNDUSJ3QRUT6IKWKRN":
+		user := *MockUser5
+		user.Suspended = true
+		return &user, nil
+	case "EXP34GKUHNDUSJ3QRUT6IKWKRI":
+		return MockUser, nil
 	default:
 		return nil, data.ErrRecordNotFound
 	}
 }
 
+func (m MockUserModel) GetForEmailRevertToken(tokenPlaintext string) (*data.User, string, error) {
+	user := *MockUser3
+
+	switch tokenPlaintext {
+	// The change to vicky.new@gmail.com has not been confirmed yet.
+	case "REV34GKUHNDUSJ3QRUT6IKWKRK":
+		user.PendingEmail = "vicky.new@gmail.com"
+		return &user, "vicky@gmail.com", nil
+	// The change to vicky.new@gmail.com has been confirmed.
+	case "REV34GKUHNDUSJ3QRUT6IKWKRL":
+		user.Email = "vicky.new@gmail.com"
+		return &user, "vicky@gmail.com", nil
+	// The change to vicky.new@gmail.com has been confirmed, and a second
+	// change away from it requested since.
+	case "REV34GKUHNDUSJ3QRUT6IKWKRM":
+		user.Email = "vicky.new@gmail.com"
+		user.PendingEmail = "vicky.other@gmail.com"
+		return &user, "vicky@gmail.com", nil
+	// The address the token restores has been taken by another user since.
+	case "REV34GKUHNDUSJ3QRUT6IKWKRN":
+		user.Email = "vicky.new@gmail.com"
+		return &user, "foo@gmail.com", nil
+	default:
+		return nil, "", data.ErrRecordNotFound
+	}
+}
+
 func (m MockUserModel) ChangePassword(id int64, newPassword string) error {
 	return nil
 }
 
 func (m MockUserModel) Delete(id int64) error {
 	switch id {
-	case 1:
+	case 1, 2, 3, 4, 5:
 		return nil
 	default:
 		return data.ErrRecordNotFound
@@ -0,0 +1,77 @@
+package mock
+
+import (
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/data"
+)
+
+// User 3 has movie 1 on their watchlist and has watched it once.
+var mockWatchedRating int32 = 9
+
+var mockWatchedEntry = &data.WatchedEntry{
+	ID:        1,
+	UserID:    3,
+	MovieID:   1,
+	WatchedOn: data.Date(time.Date(2022, time.March, 4, 0, 0, 0, 0, time.UTC)),
+	Rating:    &mockWatchedRating,
+}
+
+type MockWatchlistModel struct{}
+
+func (m MockWatchlistModel) Add(userID, movieID int64) (*data.WatchlistEntry, error) {
+	if userID == 3 && movieID == 1 {
+		return nil, data.ErrDuplicateWatchlistEntry
+	}
+	return &data.WatchlistEntry{AddedAt: time.Now()}, nil
+}
+
+func (m MockWatchlistModel) Remove(userID, movieID int64) error {
+	if userID == 3 && movieID == 1 {
+		return nil
+	}
+	return data.ErrRecordNotFound
+}
+
+func (m MockWatchlistModel) GetAll(userID int64, filters data.Filters) ([]*data.WatchlistEntry, data.Metadata, error) {
+	entries := []*data.WatchlistEntry{}
+	if userID == 3 {
+		movie := *mockMovie
+		entries = append(entries, &data.WatchlistEntry{AddedAt: time.Now(), Movie: &movie})
+	}
+	metadata := data.Metadata{}
+	return entries, metadata, nil
+}
+
+func (m MockWatchlistModel) SetFlags(userID int64, movies ...*data.Movie) error {
+	for _, movie := range movies {
+		listed := userID == 3 && movie.ID == 1
+		movie.InWatchlist = &listed
+		movie.Watched = &listed
+	}
+	return nil
+}
+
+func (m MockWatchlistModel) InsertWatched(entry *data.WatchedEntry) error {
+	entry.ID = 2
+	return nil
+}
+
+func (m MockWatchlistModel) DeleteWatched(userID, id int64) error {
+	if userID == mockWatchedEntry.UserID && id == mockWatchedEntry.ID {
+		return nil
+	}
+	return data.ErrRecordNotFound
+}
+
+func (m MockWatchlistModel) GetAllWatched(userID int64, filters data.Filters) ([]*data.WatchedEntry, data.Metadata, error) {
+	entries := []*data.WatchedEntry{}
+	if userID == mockWatchedEntry.UserID {
+		entry := *mockWatchedEntry
+		movie := *mockMovie
+		entry.Movie = &movie
+		entries = append(entries, &entry)
+	}
+	metadata := data.Metadata{}
+	return entries, metadata, nil
+}
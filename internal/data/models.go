@@ -15,28 +15,96 @@ var (
 
 type Models struct {
 	Movies interface {
-		Insert(movie *Movie) error
+		Insert(movie *Movie, userID int64) error
 		Get(id int64) (*Movie, error)
-		Update(movie *Movie) error
-		Delete(id int64) error
-		GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error)
+		Update(movie *Movie, userID int64) error
+		Delete(id int64, version int32, userID int64) error
+		GetAll(filter MovieFilter, filters Filters) ([]*Movie, Metadata, error)
+		Suggest(q string, limit int) ([]*MovieSuggestion, error)
+		GetDeleted(id int64) (*Movie, error)
+		GetAllDeleted(userID int64, filters Filters) ([]*Movie, Metadata, error)
+		GetAllForUser(userID int64) ([]*Movie, error)
+		Restore(movie *Movie, userID int64) error
+		Purge(retention time.Duration) (int64, error)
 	}
 	Tokens interface {
 		Insert(token *Token) error
 		DeleteAllForUser(scope string, userID int64, userIP *string) error
 		New(userID int64, ttl time.Duration, scope string, r *http.Request) (*Token, error)
+		NewEmailRevert(userID int64, email string, ttl time.Duration, r *http.Request) (*Token, error)
+		NewPair(userID int64, deviceName string, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error)
+		Rotate(refreshPlaintext string, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, []*Token, error)
+		GetSessionsForUser(userID int64) ([]*Session, error)
+		DeleteSession(userID, id int64) error
+		DeleteSessionForToken(userID int64, tokenHash []byte) error
+		DeleteOtherSessions(userID int64, tokenHash []byte) error
+	}
+	Revocations interface {
+		RevokeToken(jti string, expiry time.Time) error
+		RevokeUser(userID int64, at time.Time) error
+		GetAll(maxAge time.Duration) (*Revocations, error)
+		DeleteExpired(maxAge time.Duration) (int64, error)
 	}
 	Users interface {
 		Insert(user *User) error
+		Get(id int64) (*User, error)
 		GetByEmail(email string) (*User, error)
+		GetAll(search string, filter UserFilter, filters Filters) ([]*User, Metadata, error)
 		Update(user *User) error
 		GetForToken(tokenScope, tokenPlaintext string) (*User, error)
+		GetForEmailRevertToken(tokenPlaintext string) (*User, string, error)
 		ChangePassword(id int64, newPassword string) error
 		Delete(id int64) error
 	}
 	Permissions interface {
 		GetAllForUser(userID int64) (Permissions, error)
 		AddForUser(userID int64, codes ...string) error
+		RemoveForUser(userID int64, codes ...string) error
+		GetAllRoles() ([]*Role, error)
+		GetRole(id int64) (*Role, error)
+		InsertRole(role *Role) error
+		UpdateRole(role *Role) error
+		GetRolesForUser(userID int64) ([]string, error)
+		AddRolesForUser(userID int64, names ...string) error
+		RemoveRolesForUser(userID int64, names ...string) error
+		GetRequiringMFA() (Permissions, error)
+		SetRequiresMFA(code string, required bool) error
+		GetUsersWithPermission(code string) ([]int64, error)
+		GetUsersWithRole(id int64) ([]int64, error)
+	}
+	Attempts interface {
+		Get(kind, key string) (*LoginAttempts, error)
+		RecordFailure(kind, key string, threshold int, window time.Duration) (*LoginAttempts, error)
+		Reset(kind, key string) error
+		DeleteStale(window time.Duration) (int64, error)
+	}
+	MFA interface {
+		Enrol(userID int64, secret []byte) error
+		Get(userID int64) (*TOTP, error)
+		Confirm(userID int64, step int64, recoveryCodes []string) error
+		UseStep(userID int64, step int64) error
+		UseRecoveryCode(userID int64, code string) error
+		Delete(userID int64) error
+	}
+	APIKeys interface {
+		Insert(key *APIKey) error
+		GetForKey(keyPlaintext string) (*User, *APIKey, error)
+		GetAllForUser(userID int64) ([]*APIKey, error)
+		Delete(userID, id int64) error
+		DeleteAllForUser(userID int64) error
+	}
+	OAuth interface {
+		InsertClient(client *OAuthClient) error
+		GetClient(clientID string) (*OAuthClient, error)
+		GetAllClients() ([]*OAuthClient, error)
+		DeleteClient(id int64) error
+		NewCode(code *OAuthCode, ttl time.Duration, r *http.Request) (*Token, error)
+		ExchangeCode(codePlaintext string, clientID int64) (*OAuthCode, error)
+		NewTokens(userID, clientID int64, permissions Permissions, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error)
+		Rotate(refreshPlaintext string, clientID int64, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error)
+		GetForAccessToken(tokenPlaintext string) (*User, *Token, error)
+		Introspect(tokenPlaintext string, clientID int64) (*User, *Token, error)
+		Revoke(tokenPlaintext string, clientID int64) error
 	}
 	UsersProfile interface {
 		Insert(profile *UserProfile) error
@@ -45,14 +113,83 @@ type Models struct {
 		Get(userID int64) (*UserProfile, error)
 		DeletOldPicture(imagePath string) error
 	}
+	Revisions interface {
+		Get(movieID int64, version int32) (*MovieRevision, error)
+		GetAllForMovie(movieID int64, filters Filters) ([]*MovieRevision, Metadata, error)
+	}
+	Genres interface {
+		Index() (GenreIndex, error)
+		GetAll() ([]*Genre, error)
+		Get(id int64) (*Genre, error)
+		Insert(genre *Genre) error
+		Update(genre *Genre, userID int64) error
+		Merge(source, target *Genre, userID int64) error
+	}
+	People interface {
+		Insert(person *Person) error
+		Get(id int64) (*Person, error)
+		Update(person *Person) error
+		Delete(id int64) error
+		GetAll(name string, filters Filters) ([]*Person, Metadata, error)
+		InsertCredit(credit *Credit) error
+		DeleteCredit(movieID, id int64) error
+		GetCreditsForMovie(movieID int64) ([]*Credit, error)
+		GetFilmography(personID int64) ([]*Credit, error)
+	}
+	Watchlist interface {
+		Add(userID, movieID int64) (*WatchlistEntry, error)
+		Remove(userID, movieID int64) error
+		GetAll(userID int64, filters Filters) ([]*WatchlistEntry, Metadata, error)
+		SetFlags(userID int64, movies ...*Movie) error
+		InsertWatched(entry *WatchedEntry) error
+		DeleteWatched(userID, id int64) error
+		GetAllWatched(userID int64, filters Filters) ([]*WatchedEntry, Metadata, error)
+	}
+	Lists interface {
+		Insert(list *List) error
+		Get(id int64) (*List, error)
+		Update(list *List) error
+		Delete(id int64) error
+		GetAll(viewerID, ownerID int64, name string, filters Filters) ([]*List, Metadata, error)
+		GetEntries(listID int64, filters Filters) ([]*ListEntry, Metadata, error)
+		GetEntry(listID, movieID int64) (*ListEntry, error)
+		AddEntry(entry *ListEntry) error
+		UpdateEntry(entry *ListEntry) error
+		RemoveEntry(listID, movieID int64) error
+	}
+	Reviews interface {
+		Insert(review *Review) error
+		Get(id int64) (*Review, error)
+		Update(review *Review) error
+		Delete(id int64) error
+		GetAllForMovie(movieID int64, filters Filters) ([]*Review, Metadata, error)
+	}
+	Audit interface {
+		Insert(event *AuditEvent) error
+		GetAll(filter AuditFilter, filters Filters) ([]*AuditEvent, Metadata, error)
+	}
 }
 
-func NewModels(db *sql.DB) Models {
+// NewModels returns the models backed by db. cache may be nil to disable the
+// caching of token and permission lookups.
+func NewModels(db *sql.DB, cache *AuthCache, genres *GenreCache) Models {
 	return Models{
 		Movies:       MovieModel{DB: db},
-		Users:        UserModel{DB: db},
-		Tokens:       TokenModel{DB: db},
-		Permissions:  PermissionModel{DB: db},
+		Users:        UserModel{DB: db, Cache: cache},
+		Tokens:       TokenModel{DB: db, Cache: cache},
+		Revocations:  RevocationModel{DB: db},
+		Permissions:  PermissionModel{DB: db, Cache: cache},
+		MFA:          MFAModel{DB: db, Cache: cache},
+		Attempts:     AttemptModel{DB: db},
+		APIKeys:      APIKeyModel{DB: db},
+		OAuth:        OAuthModel{DB: db},
 		UsersProfile: ProfileModel{DB: db},
+		Genres:       GenreModel{DB: db, Cache: genres},
+		People:       PersonModel{DB: db},
+		Watchlist:    WatchlistModel{DB: db},
+		Lists:        ListModel{DB: db},
+		Reviews:      ReviewModel{DB: db},
+		Revisions:    RevisionModel{DB: db},
+		Audit:        AuditModel{DB: db},
 	}
 }
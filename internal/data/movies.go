@@ -5,6 +5,7 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strconv"
 	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/validator"
@@ -12,17 +13,24 @@ import (
 )
 
 type Movie struct {
-	ID        int64     `json:"id"`
-	UserID    int64     `json:"-"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
+	ID            int64      `json:"id"`
+	UserID        int64      `json:"-"`
+	CreatedAt     time.Time  `json:"-"`
+	Title         string     `json:"title"`
+	Year          int32      `json:"year,omitempty"`
+	Runtime       Runtime    `json:"runtime,omitempty"`
+	Genres        []string   `json:"genres,omitempty"`
+	Version       int32      `json:"version"`
+	AverageRating float64    `json:"average_rating"`
+	RatingCount   int64      `json:"rating_count"`
+	DeletedAt     *time.Time `json:"deleted_at,omitempty"`
+	InWatchlist   *bool      `json:"in_watchlist,omitempty"`
+	Watched       *bool      `json:"watched,omitempty"`
 }
 
-func ValidateMovie(v *validator.Validator, movie *Movie) {
+// ValidateMovie checks movie and replaces its genres by the slugs of the known
+// genres they are spellings of, rejecting the genres that are not known.
+func ValidateMovie(v *validator.Validator, movie *Movie, genres GenreIndex) {
 	v.Check(movie.Title != "", "title", "must be provided")
 	v.Check(len(movie.Title) <= 50, "title", "must not be more than 50 bytes long")
 	v.Check(movie.Year != 0, "year", "must be provided")
@@ -33,17 +41,42 @@ func ValidateMovie(v *validator.Validator, movie *Movie) {
 	v.Check(movie.Genres != nil, "genres", "must be provided")
 	v.Check(len(movie.Genres) >= 1, "genres", "must contain at least 1 genre")
 	v.Check(len(movie.Genres) <= 5, "genres", "must not contain more than 5 genres")
-	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
 	for _, value := range movie.Genres {
 		v.Check(value != "", "genres", "field must not be empty")
 	}
+
+	// The genres are normalised into a new slice, as callers may still hold
+	// on to the original one, e.g. to record the movie before a change.
+	if movie.Genres != nil {
+		normalised := make([]string, len(movie.Genres))
+		for i, value := range movie.Genres {
+			slug, ok := genres.Normalise(value)
+			if value != "" && !ok {
+				v.AddError("genres", fmt.Sprintf("must only contain known genres, %s is not one", value))
+			}
+			normalised[i] = slug
+		}
+		movie.Genres = normalised
+	}
+
+	v.Check(validator.Unique(movie.Genres), "genres", "must not contain duplicate values")
 }
 
+// ratingsQuery aggregates the reviews of the movie in the enclosing query. It
+// is joined laterally so that movies without reviews still get a single row
+// with a zero rating, which also lets "rating" be used as a sort column.
+const ratingsQuery = `
+	SELECT COALESCE(AVG(reviews.rating), 0)::float8 AS rating, count(*) AS rating_count
+	FROM reviews
+	WHERE reviews.movie_id = movies.id`
+
 type MovieModel struct {
 	DB *sql.DB
 }
 
-func (m MovieModel) Insert(movie *Movie) error {
+// Insert adds the movie, recording userID as the author of its first
+// revision.
+func (m MovieModel) Insert(movie *Movie, userID int64) error {
 
 	query := `
 	INSERT INTO movies (user_id, title, year, runtime, genres)
@@ -55,7 +88,23 @@ func (m MovieModel) Insert(movie *Movie) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.ID, &movie.CreatedAt, &movie.Version)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, movie, userID, RevisionCreate)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
 func (m MovieModel) Get(id int64) (*Movie, error) {
@@ -65,9 +114,10 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	}
 
 	query := `
-	SELECT user_id, id, created_at, title, year, runtime, genres, version
+	SELECT user_id, id, created_at, title, year, runtime, genres, version, ratings.rating, ratings.rating_count
 	FROM movies
-	WHERE id = $1`
+	LEFT JOIN LATERAL (` + ratingsQuery + `) ratings ON true
+	WHERE id = $1 AND deleted_at IS NULL`
 
 	var movie Movie
 
@@ -84,6 +134,8 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 		&movie.Runtime,
 		pq.Array(&movie.Genres),
 		&movie.Version,
+		&movie.AverageRating,
+		&movie.RatingCount,
 	)
 
 	if err != nil {
@@ -98,12 +150,13 @@ func (m MovieModel) Get(id int64) (*Movie, error) {
 	return &movie, nil
 }
 
-func (m MovieModel) Update(movie *Movie) error {
+// Update saves the movie, recording userID as the author of the revision.
+func (m MovieModel) Update(movie *Movie, userID int64) error {
 
 	query := `
 	UPDATE movies
 	SET title = $1, year = $2, runtime = $3, genres = $4, user_id = $5, version = version + 1
-	WHERE id = $6 AND version = $7
+	WHERE id = $6 AND version = $7 AND deleted_at IS NULL
 	RETURNING version`
 
 	args := []interface{}{
@@ -119,7 +172,13 @@ func (m MovieModel) Update(movie *Movie) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, args...).Scan(&movie.Version)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
@@ -128,50 +187,355 @@ func (m MovieModel) Update(movie *Movie) error {
 			return err
 		}
 	}
-	return nil
+
+	err = insertRevision(ctx, tx, movie, userID, RevisionUpdate)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
 }
 
-func (m MovieModel) Delete(id int64) error {
+// Delete moves the movie to the trash, recording userID as the author of the
+// revision.
+func (m MovieModel) Delete(id int64, version int32, userID int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 
 	query := `
-	DELETE FROM movies
-	WHERE id = $1`
+	UPDATE movies
+	SET deleted_at = NOW(), version = version + 1
+	WHERE id = $1 AND version = $2 AND deleted_at IS NULL
+	RETURNING user_id, id, title, year, runtime, genres, version`
+
+	var movie Movie
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	result, err := m.DB.ExecContext(ctx, query, id)
+	tx, err := m.DB.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	rowsAffected, err := result.RowsAffected()
+	err = tx.QueryRowContext(ctx, query, id, version).Scan(
+		&movie.UserID,
+		&movie.ID,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+	)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		// Tell a movie that was changed since version was read apart from
+		// one that is gone or already in the trash.
+		var exists bool
+		err = tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM movies WHERE id = $1 AND deleted_at IS NULL)`, id).Scan(&exists)
+		switch {
+		case err != nil:
+			return err
+		case exists:
+			return ErrEditConflict
+		default:
+			return ErrRecordNotFound
+		}
+	}
+
+	err = insertRevision(ctx, tx, &movie, userID, RevisionDelete)
 	if err != nil {
 		return err
 	}
 
-	if rowsAffected == 0 {
-		return ErrRecordNotFound
+	err = removeFromLists(ctx, tx, movie.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetDeleted returns a movie that has been moved to the trash by Delete.
+func (m MovieModel) GetDeleted(id int64) (*Movie, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT user_id, id, created_at, title, year, runtime, genres, version, deleted_at
+	FROM movies
+	WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	var movie Movie
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&movie.UserID,
+		&movie.ID,
+		&movie.CreatedAt,
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+		&movie.DeletedAt,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
 	}
-	return nil
+
+	return &movie, nil
 }
 
-func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*Movie, Metadata, error) {
+// GetAllDeleted lists the movies in the trash. A userID of 0 lists the trash
+// of every user.
+func (m MovieModel) GetAllDeleted(userID int64, filters Filters) ([]*Movie, Metadata, error) {
 	query := fmt.Sprintf(`
-	SELECT count(*) OVER(), user_id, id, created_at, title, year, runtime, genres, version
+	SELECT count(*) OVER(), user_id, id, created_at, title, year, runtime, genres, version, deleted_at
 	FROM movies
-	WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	WHERE deleted_at IS NOT NULL
+	AND (user_id = $1 OR $1 = 0)
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&totalRecords,
+			&movie.UserID,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.DeletedAt,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return movies, metadata, nil
+}
+
+// GetAllForUser returns every movie the user created, including those in the
+// trash, oldest first.
+func (m MovieModel) GetAllForUser(userID int64) ([]*Movie, error) {
+	query := `
+	SELECT id, created_at, title, year, runtime, genres, version, deleted_at
+	FROM movies
+	WHERE user_id = $1
+	ORDER BY id ASC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		movie := Movie{UserID: userID}
+
+		err := rows.Scan(
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return movies, nil
+}
+
+// Restore takes the movie out of the trash, recording userID as the author of
+// the revision.
+func (m MovieModel) Restore(movie *Movie, userID int64) error {
+	if movie.ID < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	UPDATE movies
+	SET deleted_at = NULL, version = version + 1
+	WHERE id = $1 AND deleted_at IS NOT NULL
+	RETURNING title, year, runtime, genres, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, movie.ID).Scan(
+		&movie.Title,
+		&movie.Year,
+		&movie.Runtime,
+		pq.Array(&movie.Genres),
+		&movie.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrRecordNotFound
+		default:
+			return err
+		}
+	}
+
+	err = insertRevision(ctx, tx, movie, userID, RevisionRestore)
+	if err != nil {
+		return err
+	}
+
+	movie.DeletedAt = nil
+
+	return tx.Commit()
+}
+
+// Purge permanently deletes movies that have been in the trash for longer than
+// retention and returns the number of movies removed.
+func (m MovieModel) Purge(retention time.Duration) (int64, error) {
+	query := `
+	DELETE FROM movies
+	WHERE deleted_at < $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, time.Now().Add(-retention))
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// MovieFilter narrows down a listing of movies. Director and Actor match the
+// names of the people credited with that role on a movie. Fuzzy matches the
+// title by trigram similarity instead of by its words, so that misspelt and
+// partial words find it too.
+type MovieFilter struct {
+	Title    string
+	Genres   []string
+	Director string
+	Actor    string
+	Fuzzy    bool
+}
+
+// where returns the WHERE clause of the movie listings. It takes the fields of
+// a MovieFilter as the parameters $1 to $4, in the order returned by args.
+func (f MovieFilter) where() string {
+	title := `to_tsvector('simple', title) @@ plainto_tsquery('simple', $1)`
+	if f.Fuzzy {
+		title = `$1 <% title`
+	}
+
+	return `
+	WHERE (` + title + ` OR $1 = '')
 	AND (genres @> $2 OR $2 = '{}')
+	AND ($3 = '' OR movies.id IN (
+		SELECT movie_credits.movie_id FROM movie_credits INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.role = 'director' AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', $3)))
+	AND ($4 = '' OR movies.id IN (
+		SELECT movie_credits.movie_id FROM movie_credits INNER JOIN people ON people.id = movie_credits.person_id
+		WHERE movie_credits.role = 'actor' AND to_tsvector('simple', people.name) @@ plainto_tsquery('simple', $4)))
+	AND deleted_at IS NULL`
+}
+
+// relevance returns how far the title of a movie is from matching f.Title, so
+// that sorting on it ascending lists the best matches first.
+func (f MovieFilter) relevance() string {
+	if f.Fuzzy {
+		return `($1 <<-> title)`
+	}
+	return `(-ts_rank(to_tsvector('simple', title), plainto_tsquery('simple', $1)))`
+}
+
+// orderBy returns the column or expression the listing is sorted on.
+func (f MovieFilter) orderBy(filters Filters) string {
+	if column := filters.sortColumn(); column != "relevance" {
+		return column
+	}
+	return f.relevance()
+}
+
+func (f MovieFilter) args() []interface{} {
+	return []interface{}{f.Title, pq.Array(f.Genres), f.Director, f.Actor}
+}
+
+func (m MovieModel) GetAll(filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	if filters.Cursor != nil {
+		return m.getAllKeyset(filter, filters)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), user_id, id, created_at, title, year, runtime, genres, version, ratings.rating, ratings.rating_count
+	FROM movies
+	LEFT JOIN LATERAL (`+ratingsQuery+`) ratings ON true%s
 	ORDER BY %s %s, id ASC
-	LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
+	LIMIT $5 OFFSET $6`, filter.where(), filter.orderBy(filters), filters.sortDirection())
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
+	args := append(filter.args(), filters.limit(), filters.offset())
 
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -196,6 +560,8 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 			&movie.Runtime,
 			pq.Array(&movie.Genres),
 			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
 		)
 		if err != nil {
 			return nil, Metadata{}, err
@@ -212,3 +578,165 @@ func (m MovieModel) GetAll(title string, genres []string, filters Filters) ([]*M
 
 	return movies, metadata, nil
 }
+
+// getAllKeyset pages through movies by seeking past filters.Cursor instead of
+// using an offset, so it skips the total record count and stays fast on deep
+// pages.
+func (m MovieModel) getAllKeyset(filter MovieFilter, filters Filters) ([]*Movie, Metadata, error) {
+	operator, direction := filters.keyset()
+	column := filters.sortColumn()
+
+	cursor := filters.Cursor
+	args := append(filter.args(), filters.limit()+1)
+
+	seek := ""
+	if cursor.ID != 0 {
+		seek = fmt.Sprintf("AND (%s, id) %s ($6, $7)", column, operator)
+		args = append(args, cursor.Value, cursor.ID)
+	}
+
+	query := fmt.Sprintf(`
+	SELECT user_id, id, created_at, title, year, runtime, genres, version, ratings.rating, ratings.rating_count
+	FROM movies
+	LEFT JOIN LATERAL (`+ratingsQuery+`) ratings ON true%s
+	%s
+	ORDER BY %s %s, id %s
+	LIMIT $5`, filter.where(), seek, column, direction, direction)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	movies := []*Movie{}
+
+	for rows.Next() {
+		var movie Movie
+
+		err := rows.Scan(
+			&movie.UserID,
+			&movie.ID,
+			&movie.CreatedAt,
+			&movie.Title,
+			&movie.Year,
+			&movie.Runtime,
+			pq.Array(&movie.Genres),
+			&movie.Version,
+			&movie.AverageRating,
+			&movie.RatingCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		movies = append(movies, &movie)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	more := len(movies) > filters.limit()
+	if more {
+		movies = movies[:filters.limit()]
+	}
+
+	if cursor.Before {
+		for i, j := 0, len(movies)-1; i < j; i, j = i+1, j-1 {
+			movies[i], movies[j] = movies[j], movies[i]
+		}
+	}
+
+	metadata := Metadata{PageSize: filters.PageSize}
+	if len(movies) == 0 {
+		return movies, metadata, nil
+	}
+
+	hasNext := more || (cursor.Before && cursor.ID != 0)
+	hasPrev := (!cursor.Before && cursor.ID != 0) || (cursor.Before && more)
+
+	if hasNext {
+		metadata.Next = movieCursor(movies[len(movies)-1], filters, false)
+	}
+	if hasPrev {
+		metadata.Prev = movieCursor(movies[0], filters, true)
+	}
+
+	return movies, metadata, nil
+}
+
+func movieCursor(movie *Movie, filters Filters, before bool) *Cursor {
+	var value string
+
+	switch filters.sortColumn() {
+	case "id":
+		value = strconv.FormatInt(movie.ID, 10)
+	case "title":
+		value = movie.Title
+	case "year":
+		value = strconv.FormatInt(int64(movie.Year), 10)
+	case "runtime":
+		value = strconv.FormatInt(int64(movie.Runtime), 10)
+	case "rating":
+		value = strconv.FormatFloat(movie.AverageRating, 'g', -1, 64)
+	}
+
+	return &Cursor{
+		Sort:   filters.Sort,
+		Value:  value,
+		ID:     movie.ID,
+		Before: before,
+	}
+}
+
+// MovieSuggestion is a movie whose title completes a search being typed.
+type MovieSuggestion struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Year  int32  `json:"year,omitempty"`
+}
+
+// Suggest returns up to limit movies whose title starts with q, or has a word
+// that does, followed by those whose title is similar to q. Movies in the
+// trash are left out.
+func (m MovieModel) Suggest(q string, limit int) ([]*MovieSuggestion, error) {
+	query := `
+	SELECT id, title, year
+	FROM movies
+	WHERE deleted_at IS NULL
+	AND (title ILIKE $1 || '%' OR title ILIKE '% ' || $1 || '%' OR $2 <% title)
+	ORDER BY title ILIKE $1 || '%' DESC, title ILIKE '% ' || $1 || '%' DESC, $2 <<-> title, title, id
+	LIMIT $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, likeEscaper.Replace(q), q, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	suggestions := []*MovieSuggestion{}
+
+	for rows.Next() {
+		var suggestion MovieSuggestion
+
+		err := rows.Scan(&suggestion.ID, &suggestion.Title, &suggestion.Year)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestions = append(suggestions, &suggestion)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return suggestions, nil
+}
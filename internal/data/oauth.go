@@ -0,0 +1,579 @@
+package data
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"database/sql"
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+// OAuthClient is a third-party application registered to act on behalf of
+// users through the OAuth2 authorization server. Confidential clients hold a
+// secret and may also use the client_credentials grant, acting as the user
+// who registered them.
+type OAuthClient struct {
+	ID           int64       `json:"id"`
+	ClientID     string      `json:"client_id"`
+	Secret       string      `json:"client_secret,omitempty"`
+	SecretHash   []byte      `json:"-"`
+	Name         string      `json:"name"`
+	RedirectURIs []string    `json:"redirect_uris"`
+	Scopes       Permissions `json:"scopes"`
+	Confidential bool        `json:"confidential"`
+	UserID       int64       `json:"user_id"`
+	CreatedAt    time.Time   `json:"created_at"`
+}
+
+// OAuthCode is an authorization code waiting to be exchanged for tokens.
+type OAuthCode struct {
+	UserID        int64
+	ClientID      int64
+	Permissions   Permissions
+	RedirectURI   string
+	CodeChallenge string
+}
+
+func randomString(n int) (string, error) {
+	randomBytes := make([]byte, n)
+
+	_, err := rand.Read(randomBytes)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(randomBytes), nil
+}
+
+// NewOAuthClient returns a client with a random client_id and, for
+// confidential clients, a random secret that is only ever shown once.
+func NewOAuthClient(userID int64, name string, redirectURIs []string, scopes Permissions, confidential bool) (*OAuthClient, error) {
+	clientID, err := randomString(16)
+	if err != nil {
+		return nil, err
+	}
+
+	client := &OAuthClient{
+		ClientID:     clientID,
+		Name:         name,
+		RedirectURIs: redirectURIs,
+		Scopes:       scopes,
+		Confidential: confidential,
+		UserID:       userID,
+	}
+
+	if confidential {
+		client.Secret, err = randomString(32)
+		if err != nil {
+			return nil, err
+		}
+		hash := sha256.Sum256([]byte(client.Secret))
+		client.SecretHash = hash[:]
+	}
+
+	return client, nil
+}
+
+// SecretMatches reports whether secret is the secret of a confidential client.
+func (c *OAuthClient) SecretMatches(secret string) bool {
+	if !c.Confidential || secret == "" {
+		return false
+	}
+	hash := sha256.Sum256([]byte(secret))
+	return subtle.ConstantTimeCompare(hash[:], c.SecretHash) == 1
+}
+
+// HasRedirectURI reports whether uri exactly matches one of the registered
+// redirect URIs.
+func (c *OAuthClient) HasRedirectURI(uri string) bool {
+	return validator.In(uri, c.RedirectURIs...)
+}
+
+func ValidateOAuthClient(v *validator.Validator, client *OAuthClient) {
+	v.Check(client.Name != "", "name", "must be provided")
+	v.Check(len(client.Name) <= 100, "name", "must not be more than 100 bytes long")
+	v.Check(len(client.RedirectURIs) >= 1, "redirect_uris", "must contain at least 1 uri")
+	v.Check(validator.Unique(client.RedirectURIs), "redirect_uris", "must not contain duplicate values")
+	for _, uri := range client.RedirectURIs {
+		v.Check(validRedirectURI(uri), "redirect_uris", "must only contain absolute https uris without a fragment, or http uris on localhost")
+	}
+	v.Check(len(client.Scopes) >= 1, "scopes", "must contain at least 1 permission")
+	v.Check(validator.Unique(client.Scopes), "scopes", "must not contain duplicate values")
+}
+
+func validRedirectURI(uri string) bool {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" || u.Fragment != "" {
+		return false
+	}
+	switch u.Scheme {
+	case "https":
+		return true
+	case "http":
+		return u.Hostname() == "localhost" || u.Hostname() == "127.0.0.1"
+	default:
+		return false
+	}
+}
+
+type OAuthModel struct {
+	DB *sql.DB
+}
+
+func (m OAuthModel) InsertClient(client *OAuthClient) error {
+	query := `
+	INSERT INTO oauth_clients (client_id, secret_hash, name, redirect_uris, scopes, user_id)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING id, created_at`
+
+	args := []interface{}{client.ClientID, client.SecretHash, client.Name, pq.Array(client.RedirectURIs), pq.Array(client.Scopes), client.UserID}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&client.ID, &client.CreatedAt)
+}
+
+const oauthClientColumns = `id, client_id, secret_hash, name, redirect_uris, scopes, user_id, created_at`
+
+func scanOAuthClient(row interface{ Scan(...interface{}) error }) (*OAuthClient, error) {
+	var client OAuthClient
+
+	err := row.Scan(
+		&client.ID,
+		&client.ClientID,
+		&client.SecretHash,
+		&client.Name,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.Scopes),
+		&client.UserID,
+		&client.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	client.Confidential = client.SecretHash != nil
+	return &client, nil
+}
+
+// GetClient returns the client with the given public client_id.
+func (m OAuthModel) GetClient(clientID string) (*OAuthClient, error) {
+	query := `
+	SELECT ` + oauthClientColumns + `
+	FROM oauth_clients
+	WHERE client_id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	client, err := scanOAuthClient(m.DB.QueryRowContext(ctx, query, clientID))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return client, nil
+}
+
+func (m OAuthModel) GetAllClients() ([]*OAuthClient, error) {
+	query := `
+	SELECT ` + oauthClientColumns + `
+	FROM oauth_clients
+	ORDER BY id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	clients := []*OAuthClient{}
+
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+
+		clients = append(clients, client)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return clients, nil
+}
+
+// DeleteClient removes a client along with every code and token issued to it.
+func (m OAuthModel) DeleteClient(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM oauth_clients
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// NewCode issues an authorization code for the user to hand to the client.
+func (m OAuthModel) NewCode(code *OAuthCode, ttl time.Duration, r *http.Request) (*Token, error) {
+	token, err := generateToken(code.UserID, ttl, ScopeOAuthCode, r)
+	if err != nil {
+		return nil, err
+	}
+
+	token.ClientID = code.ClientID
+	token.Permissions = code.Permissions
+
+	query := `
+	INSERT INTO tokens (hash, user_id, user_ip, user_agent, expiry, scope, client_id, permissions, redirect_uri, code_challenge)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	args := []interface{}{
+		token.Hash,
+		token.UserID,
+		token.UserIP,
+		token.UserAgent,
+		token.Expiry,
+		token.Scope,
+		token.ClientID,
+		pq.Array(token.Permissions),
+		code.RedirectURI,
+		code.CodeChallenge,
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err = m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// ExchangeCode consumes an unexpired authorization code issued to the client.
+// The code is used up even if the caller then rejects the exchange.
+func (m OAuthModel) ExchangeCode(codePlaintext string, clientID int64) (*OAuthCode, error) {
+	codeHash := sha256.Sum256([]byte(codePlaintext))
+
+	query := `
+	DELETE FROM tokens
+	WHERE hash = $1 AND scope = $2 AND client_id = $3 AND expiry > $4
+	RETURNING user_id, client_id, permissions, redirect_uri, code_challenge`
+
+	var code OAuthCode
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, codeHash[:], ScopeOAuthCode, clientID, time.Now()).Scan(
+		&code.UserID,
+		&code.ClientID,
+		pq.Array(&code.Permissions),
+		&code.RedirectURI,
+		&code.CodeChallenge,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &code, nil
+}
+
+// NewTokens issues an access token for the client to act as the user with
+// permissions, and a refresh token starting a new family unless refreshTTL is
+// zero.
+func (m OAuthModel) NewTokens(userID, clientID int64, permissions Permissions, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	access, refresh, err := insertOAuthTokens(ctx, tx, userID, clientID, nil, permissions, accessTTL, refreshTTL, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// Rotate exchanges an OAuth refresh token of the client for a new pair with
+// the same permissions, following the same single use and reuse detection
+// rules as TokenModel.Rotate.
+func (m OAuthModel) Rotate(refreshPlaintext string, clientID int64, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error) {
+	tokenHash := sha256.Sum256([]byte(refreshPlaintext))
+
+	query := `
+	SELECT user_id, family, permissions, used_at, expiry
+	FROM tokens
+	WHERE hash = $1 AND scope = $2 AND client_id = $3
+	FOR UPDATE`
+
+	var (
+		userID      int64
+		family      []byte
+		permissions Permissions
+		usedAt      sql.NullTime
+		expiry      time.Time
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:], ScopeOAuthRefresh, clientID).Scan(&userID, &family, pq.Array(&permissions), &usedAt, &expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	if usedAt.Valid {
+		_, err = tx.ExecContext(ctx, "DELETE FROM tokens WHERE family = $1", family)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, nil, ErrTokenReused
+	}
+
+	if !expiry.After(time.Now()) {
+		return nil, nil, ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE tokens SET used_at = now() WHERE hash = $1", tokenHash[:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM tokens WHERE family = $1 AND scope = $2", family, ScopeOAuthAccess)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	access, refresh, err := insertOAuthTokens(ctx, tx, userID, clientID, family, permissions, accessTTL, refreshTTL, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// insertOAuthTokens generates and stores an access token, and a refresh token
+// unless refreshTTL is zero, in family. A nil family starts a new one.
+func insertOAuthTokens(ctx context.Context, tx *sql.Tx, userID, clientID int64, family []byte, permissions Permissions, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error) {
+	access, err := generateToken(userID, accessTTL, ScopeOAuthAccess, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokens := []*Token{access}
+
+	var refresh *Token
+	if refreshTTL > 0 {
+		refresh, err = generateToken(userID, refreshTTL, ScopeOAuthRefresh, r)
+		if err != nil {
+			return nil, nil, err
+		}
+		tokens = append(tokens, refresh)
+	}
+
+	if family == nil {
+		family = tokens[len(tokens)-1].Hash
+	}
+
+	query := `
+	INSERT INTO tokens (hash, user_id, user_ip, user_agent, expiry, scope, family, client_id, permissions)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	for _, token := range tokens {
+		token.Family = family
+		token.ClientID = clientID
+		token.Permissions = permissions
+		args := []interface{}{token.Hash, token.UserID, token.UserIP, token.UserAgent, token.Expiry, token.Scope, token.Family, token.ClientID, pq.Array(token.Permissions)}
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return access, refresh, nil
+}
+
+// GetForAccessToken returns the user an unexpired OAuth access token acts as,
+// along with the token, recording that the token has been used.
+func (m OAuthModel) GetForAccessToken(tokenPlaintext string) (*User, *Token, error) {
+	query := `
+	WITH token AS (
+		UPDATE tokens
+		SET last_used_at = now()
+		WHERE hash = $1
+		AND scope = ANY($2)
+		AND expiry > $3
+		RETURNING user_id, scope, expiry, client_id, permissions
+	)
+	SELECT ` + oauthTokenUserColumns + `
+	FROM users
+	INNER JOIN token
+	ON users.id = token.user_id`
+
+	return m.getForToken(query, tokenPlaintext, []string{ScopeOAuthAccess})
+}
+
+// Introspect returns an unexpired access or refresh token issued to the
+// client, along with the user it acts as.
+func (m OAuthModel) Introspect(tokenPlaintext string, clientID int64) (*User, *Token, error) {
+	query := `
+	WITH token AS (
+		SELECT user_id, scope, expiry, client_id, permissions
+		FROM tokens
+		WHERE hash = $1
+		AND scope = ANY($2)
+		AND expiry > $3
+		AND client_id = $4
+		AND used_at IS NULL
+	)
+	SELECT ` + oauthTokenUserColumns + `
+	FROM users
+	INNER JOIN token
+	ON users.id = token.user_id`
+
+	return m.getForToken(query, tokenPlaintext, []string{ScopeOAuthAccess, ScopeOAuthRefresh}, clientID)
+}
+
+const oauthTokenUserColumns = `users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.admin, users.suspended, ` + mfaEnabledQuery + `, COALESCE(users.pending_email, ''), users.version,
+	token.scope, token.expiry, token.client_id, token.permissions`
+
+// getForToken runs a query looking up an unexpired token in one of scopes,
+// with the hash, scopes and current time as its first three arguments.
+func (m OAuthModel) getForToken(query, tokenPlaintext string, scopes []string, extraArgs ...interface{}) (*User, *Token, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	args := append([]interface{}{tokenHash[:], pq.Array(scopes), time.Now()}, extraArgs...)
+
+	var user User
+	var token Token
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Admin,
+		&user.Suspended,
+		&user.MFAEnabled,
+		&user.PendingEmail,
+		&user.Version,
+		&token.Scope,
+		&token.Expiry,
+		&token.ClientID,
+		pq.Array(&token.Permissions),
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, err
+		}
+	}
+
+	token.Plaintext = tokenPlaintext
+	token.Hash = tokenHash[:]
+	token.UserID = user.ID
+
+	return &user, &token, nil
+}
+
+// Revoke revokes an access or refresh token issued to the client along with
+// the rest of its family. Tokens that do not exist or belong to another client
+// are ignored, as RFC 7009 asks.
+func (m OAuthModel) Revoke(tokenPlaintext string, clientID int64) error {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
+	query := `
+	WITH token AS (
+		SELECT id, family FROM tokens WHERE hash = $1 AND client_id = $2 AND scope = ANY($3)
+	)
+	DELETE FROM tokens
+	WHERE id IN (SELECT id FROM token) OR family IN (SELECT family FROM token)`
+
+	args := []interface{}{tokenHash[:], clientID, pq.Array([]string{ScopeOAuthAccess, ScopeOAuthRefresh})}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	return err
+}
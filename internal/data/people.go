@@ -0,0 +1,413 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var (
+	ErrInvalidDateFormat = errors.New("invalid date format")
+	ErrDuplicateCredit   = errors.New("duplicate credit")
+	ErrUnknownPerson     = errors.New("unknown person")
+)
+
+const dateLayout = "2006-01-02"
+
+// Date is a calendar date, written to and read from JSON as YYYY-MM-DD.
+type Date time.Time
+
+func (d Date) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Quote(time.Time(d).Format(dateLayout))), nil
+}
+
+func (d *Date) UnmarshalJSON(jsonValue []byte) error {
+	unquotedJSONValue, err := strconv.Unquote(string(jsonValue))
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	t, err := time.Parse(dateLayout, unquotedJSONValue)
+	if err != nil {
+		return ErrInvalidDateFormat
+	}
+
+	*d = Date(t)
+	return nil
+}
+
+type Person struct {
+	ID        int64     `json:"id"`
+	CreatedAt time.Time `json:"-"`
+	Name      string    `json:"name"`
+	BirthDate *Date     `json:"birth_date,omitempty"`
+	Bio       string    `json:"bio,omitempty"`
+	Version   int32     `json:"version"`
+}
+
+func ValidatePerson(v *validator.Validator, person *Person) {
+	v.Check(person.Name != "", "name", "must be provided")
+	v.Check(len(person.Name) <= 200, "name", "must not be more than 200 bytes long")
+	v.Check(len(person.Bio) <= 5000, "bio", "must not be more than 5000 bytes long")
+
+	if person.BirthDate != nil {
+		v.Check(time.Time(*person.BirthDate).Year() >= 1800, "birth_date", "must be after 1800")
+		v.Check(time.Time(*person.BirthDate).Before(time.Now()), "birth_date", "must not be in the future")
+	}
+}
+
+// The roles a person can be credited with on a movie.
+const (
+	CreditDirector = "director"
+	CreditWriter   = "writer"
+	CreditActor    = "actor"
+)
+
+// Credit is the role a person had on a movie. Person is filled in when
+// listing the credits of a movie and Movie when listing the filmography of a
+// person.
+type Credit struct {
+	ID        int64   `json:"id"`
+	MovieID   int64   `json:"movie_id"`
+	PersonID  int64   `json:"person_id"`
+	Role      string  `json:"role"`
+	Character string  `json:"character,omitempty"`
+	Person    *Person `json:"person,omitempty"`
+	Movie     *Movie  `json:"movie,omitempty"`
+}
+
+func ValidateCredit(v *validator.Validator, credit *Credit) {
+	v.Check(credit.PersonID > 0, "person_id", "must be provided")
+	v.Check(validator.In(credit.Role, CreditDirector, CreditWriter, CreditActor), "role", "must be one of director, writer or actor")
+	v.Check(len(credit.Character) <= 200, "character", "must not be more than 200 bytes long")
+	v.Check(credit.Character == "" || credit.Role == CreditActor, "character", "must only be provided for actors")
+}
+
+type PersonModel struct {
+	DB *sql.DB
+}
+
+func (m PersonModel) Insert(person *Person) error {
+	query := `
+	INSERT INTO people (name, birth_date, bio)
+	VALUES ($1, $2, $3)
+	RETURNING id, created_at, version`
+
+	args := []interface{}{person.Name, (*time.Time)(person.BirthDate), person.Bio}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&person.ID, &person.CreatedAt, &person.Version)
+}
+
+func (m PersonModel) Get(id int64) (*Person, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, name, birth_date, bio, version
+	FROM people
+	WHERE id = $1`
+
+	var person Person
+	var birthDate sql.NullTime
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&person.ID,
+		&person.CreatedAt,
+		&person.Name,
+		&birthDate,
+		&person.Bio,
+		&person.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if birthDate.Valid {
+		person.BirthDate = (*Date)(&birthDate.Time)
+	}
+
+	return &person, nil
+}
+
+func (m PersonModel) Update(person *Person) error {
+	query := `
+	UPDATE people
+	SET name = $1, birth_date = $2, bio = $3, version = version + 1
+	WHERE id = $4 AND version = $5
+	RETURNING version`
+
+	args := []interface{}{person.Name, (*time.Time)(person.BirthDate), person.Bio, person.ID, person.Version}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&person.Version)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+// Delete deletes the person along with their credits.
+func (m PersonModel) Delete(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	DELETE FROM people
+	WHERE id = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m PersonModel) GetAll(name string, filters Filters) ([]*Person, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, name, birth_date, bio, version
+	FROM people
+	WHERE (to_tsvector('simple', name) @@ plainto_tsquery('simple', $1) OR $1 = '')
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, name, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	people := []*Person{}
+
+	for rows.Next() {
+		var person Person
+		var birthDate sql.NullTime
+
+		err := rows.Scan(
+			&totalRecords,
+			&person.ID,
+			&person.CreatedAt,
+			&person.Name,
+			&birthDate,
+			&person.Bio,
+			&person.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		if birthDate.Valid {
+			person.BirthDate = (*Date)(&birthDate.Time)
+		}
+
+		people = append(people, &person)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return people, metadata, nil
+}
+
+func (m PersonModel) InsertCredit(credit *Credit) error {
+	query := `
+	INSERT INTO movie_credits (movie_id, person_id, role, character)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+
+	args := []interface{}{credit.MovieID, credit.PersonID, credit.Role, credit.Character}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(&credit.ID)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "movie_credits_movie_id_person_id_role_character_key"`:
+			return ErrDuplicateCredit
+		case err.Error() == `pq: insert or update on table "movie_credits" violates foreign key constraint "movie_credits_person_id_fkey"`:
+			return ErrUnknownPerson
+		default:
+			return err
+		}
+	}
+	return nil
+}
+
+func (m PersonModel) DeleteCredit(movieID, id int64) error {
+	query := `
+	DELETE FROM movie_credits
+	WHERE id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetCreditsForMovie returns the credits of the movie with the people they
+// belong to, directors first, then writers and actors.
+func (m PersonModel) GetCreditsForMovie(movieID int64) ([]*Credit, error) {
+	query := `
+	SELECT movie_credits.id, movie_credits.person_id, movie_credits.role, movie_credits.character,
+		people.name, people.birth_date, people.bio, people.version
+	FROM movie_credits
+	INNER JOIN people ON people.id = movie_credits.person_id
+	WHERE movie_credits.movie_id = $1
+	ORDER BY array_position(ARRAY['director', 'writer', 'actor'], movie_credits.role), movie_credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, movieID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		credit := Credit{MovieID: movieID, Person: &Person{}}
+		var birthDate sql.NullTime
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.PersonID,
+			&credit.Role,
+			&credit.Character,
+			&credit.Person.Name,
+			&birthDate,
+			&credit.Person.Bio,
+			&credit.Person.Version,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credit.Person.ID = credit.PersonID
+		if birthDate.Valid {
+			credit.Person.BirthDate = (*Date)(&birthDate.Time)
+		}
+
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
+
+// GetFilmography returns the credits of the person on movies that are not in
+// the trash, newest movies first.
+func (m PersonModel) GetFilmography(personID int64) ([]*Credit, error) {
+	query := `
+	SELECT movie_credits.id, movie_credits.movie_id, movie_credits.role, movie_credits.character,
+		movies.title, movies.year, movies.runtime, movies.genres, movies.version, ratings.rating, ratings.rating_count
+	FROM movie_credits
+	INNER JOIN movies ON movies.id = movie_credits.movie_id
+	LEFT JOIN LATERAL (` + ratingsQuery + `) ratings ON true
+	WHERE movie_credits.person_id = $1
+	AND movies.deleted_at IS NULL
+	ORDER BY movies.year DESC, movies.id DESC, movie_credits.id`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, personID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	credits := []*Credit{}
+
+	for rows.Next() {
+		credit := Credit{PersonID: personID, Movie: &Movie{}}
+
+		err := rows.Scan(
+			&credit.ID,
+			&credit.MovieID,
+			&credit.Role,
+			&credit.Character,
+			&credit.Movie.Title,
+			&credit.Movie.Year,
+			&credit.Movie.Runtime,
+			pq.Array(&credit.Movie.Genres),
+			&credit.Movie.Version,
+			&credit.Movie.AverageRating,
+			&credit.Movie.RatingCount,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		credit.Movie.ID = credit.MovieID
+		credits = append(credits, &credit)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return credits, nil
+}
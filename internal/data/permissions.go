@@ -6,11 +6,29 @@ import (
 	"errors"
 	"time"
 
+	"github.com/IfedayoAwe/greenlight/internal/validator"
 	"github.com/lib/pq"
 )
 
 type Permissions []string
 
+type Role struct {
+	ID          int64       `json:"id"`
+	Name        string      `json:"name"`
+	Permissions Permissions `json:"permissions"`
+	Version     int32       `json:"version"`
+}
+
+func ValidateRole(v *validator.Validator, role *Role) {
+	v.Check(role.Name != "", "name", "must be provided")
+	v.Check(len(role.Name) <= 50, "name", "must not be more than 50 bytes long")
+	v.Check(role.Permissions != nil, "permissions", "must be provided")
+	v.Check(validator.Unique(role.Permissions), "permissions", "must not contain duplicate values")
+	for _, code := range role.Permissions {
+		v.Check(code != "", "permissions", "must not contain empty values")
+	}
+}
+
 func (p Permissions) Include(code string) bool {
 	for i := range p {
 		if code == p[i] {
@@ -21,16 +39,27 @@ func (p Permissions) Include(code string) bool {
 }
 
 type PermissionModel struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Cache *AuthCache
 }
 
 func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
+	permissions, gen, ok := m.Cache.getPermissions(userID)
+	if ok {
+		return permissions, nil
+	}
+
 	query := `
 		SELECT permissions.code
 		FROM permissions
 		INNER JOIN users_permissions ON users_permissions.permission_id = permissions.id
-		INNER JOIN users ON users_permissions.user_id = users.id
-		WHERE users.id = $1`
+		WHERE users_permissions.user_id = $1
+		UNION
+		SELECT permissions.code
+		FROM permissions
+		INNER JOIN roles_permissions ON roles_permissions.permission_id = permissions.id
+		INNER JOIN users_roles ON users_roles.role_id = roles_permissions.role_id
+		WHERE users_roles.user_id = $1`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -40,7 +69,6 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 		return nil, err
 	}
 	defer rows.Close()
-	var permissions Permissions
 	for rows.Next() {
 		var permission string
 		err := rows.Scan(&permission)
@@ -52,11 +80,17 @@ func (m PermissionModel) GetAllForUser(userID int64) (Permissions, error) {
 	if err = rows.Err(); err != nil {
 		return nil, err
 	}
+
+	m.Cache.setPermissions(userID, permissions, gen)
+
 	return permissions, nil
 }
 
 var (
 	ErrDuplicatePermission = errors.New("duplicate permission made")
+	ErrUnknownPermission   = errors.New("unknown permission")
+	ErrDuplicateRole       = errors.New("duplicate role")
+	ErrUnknownRole         = errors.New("unknown role")
 )
 
 func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
@@ -76,5 +110,421 @@ func (m PermissionModel) AddForUser(userID int64, codes ...string) error {
 			return err
 		}
 	}
+
+	m.Cache.invalidatePermissions(userID)
+	return nil
+}
+
+func (m PermissionModel) RemoveForUser(userID int64, codes ...string) error {
+	query := `
+	DELETE FROM users_permissions
+	WHERE user_id = $1
+	AND permission_id IN (SELECT id FROM permissions WHERE code = ANY($2))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, pq.Array(codes))
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidatePermissions(userID)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+func (m PermissionModel) GetAllRoles() ([]*Role, error) {
+	query := `
+	SELECT roles.id, roles.name, roles.version,
+	COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+	FROM roles
+	LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+	LEFT JOIN permissions ON roles_permissions.permission_id = permissions.id
+	GROUP BY roles.id
+	ORDER BY roles.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []*Role{}
+	for rows.Next() {
+		var role Role
+		err := rows.Scan(&role.ID, &role.Name, &role.Version, pq.Array(&role.Permissions))
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, &role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (m PermissionModel) GetRole(id int64) (*Role, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT roles.id, roles.name, roles.version,
+	COALESCE(array_agg(permissions.code ORDER BY permissions.code) FILTER (WHERE permissions.code IS NOT NULL), '{}')
+	FROM roles
+	LEFT JOIN roles_permissions ON roles_permissions.role_id = roles.id
+	LEFT JOIN permissions ON roles_permissions.permission_id = permissions.id
+	WHERE roles.id = $1
+	GROUP BY roles.id`
+
+	var role Role
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(&role.ID, &role.Name, &role.Version, pq.Array(&role.Permissions))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &role, nil
+}
+
+func (m PermissionModel) InsertRole(role *Role) error {
+	query := `
+	INSERT INTO roles (name)
+	VALUES ($1)
+	RETURNING id, version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, role.Name).Scan(&role.ID, &role.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"`:
+			return ErrDuplicateRole
+		default:
+			return err
+		}
+	}
+
+	err = setRolePermissions(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (m PermissionModel) UpdateRole(role *Role) error {
+	query := `
+	UPDATE roles
+	SET name = $1, version = version + 1
+	WHERE id = $2 AND version = $3
+	RETURNING version`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, role.Name, role.ID, role.Version).Scan(&role.Version)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "roles_name_key"`:
+			return ErrDuplicateRole
+		case errors.Is(err, sql.ErrNoRows):
+			return ErrEditConflict
+		default:
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM roles_permissions WHERE role_id = $1", role.ID)
+	if err != nil {
+		return err
+	}
+
+	err = setRolePermissions(ctx, tx, role)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	// Every user holding the role may have had their permissions changed.
+	m.Cache.invalidateAllPermissions()
+	return nil
+}
+
+// setRolePermissions grants the permissions of role to it, failing with
+// ErrUnknownPermission if any of the codes does not exist.
+func setRolePermissions(ctx context.Context, tx *sql.Tx, role *Role) error {
+	query := `
+	INSERT INTO roles_permissions
+	SELECT $1, permissions.id FROM permissions WHERE permissions.code = ANY($2)`
+
+	result, err := tx.ExecContext(ctx, query, role.ID, pq.Array(role.Permissions))
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected != int64(len(role.Permissions)) {
+		return ErrUnknownPermission
+	}
+	return nil
+}
+
+func (m PermissionModel) GetRolesForUser(userID int64) ([]string, error) {
+	query := `
+	SELECT roles.name
+	FROM roles
+	INNER JOIN users_roles ON users_roles.role_id = roles.id
+	WHERE users_roles.user_id = $1
+	ORDER BY roles.name`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	roles := []string{}
+	for rows.Next() {
+		var role string
+		err := rows.Scan(&role)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (m PermissionModel) AddRolesForUser(userID int64, names ...string) error {
+	query := `
+	INSERT INTO users_roles
+	SELECT $1, roles.id FROM roles WHERE roles.name = ANY($2)
+	ON CONFLICT DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var known int
+	err = tx.QueryRowContext(ctx, "SELECT count(*) FROM roles WHERE name = ANY($1)", pq.Array(names)).Scan(&known)
+	if err != nil {
+		return err
+	}
+
+	if known != len(names) {
+		return ErrUnknownRole
+	}
+
+	_, err = tx.ExecContext(ctx, query, userID, pq.Array(names))
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidatePermissions(userID)
+	return nil
+}
+
+func (m PermissionModel) RemoveRolesForUser(userID int64, names ...string) error {
+	query := `
+	DELETE FROM users_roles
+	WHERE user_id = $1
+	AND role_id IN (SELECT id FROM roles WHERE name = ANY($2))`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, pq.Array(names))
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidatePermissions(userID)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
 	return nil
 }
+
+// GetRequiringMFA returns the permissions that are only granted to users with
+// two-factor authentication enabled.
+func (m PermissionModel) GetRequiringMFA() (Permissions, error) {
+	permissions, gen, ok := m.Cache.getRequiringMFA()
+	if ok {
+		return permissions, nil
+	}
+
+	query := `
+	SELECT code
+	FROM permissions
+	WHERE requires_mfa = true`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var permission string
+		err := rows.Scan(&permission)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	m.Cache.setRequiringMFA(permissions, gen)
+
+	return permissions, nil
+}
+
+func (m PermissionModel) SetRequiresMFA(code string, required bool) error {
+	query := `
+	UPDATE permissions
+	SET requires_mfa = $2
+	WHERE code = $1`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, code, required)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	m.Cache.invalidateRequiringMFA()
+
+	return nil
+}
+
+// GetUsersWithPermission returns the ids of the users granted the permission,
+// either directly or through one of their roles.
+func (m PermissionModel) GetUsersWithPermission(code string) ([]int64, error) {
+	query := `
+	SELECT users_permissions.user_id
+	FROM users_permissions
+	INNER JOIN permissions ON permissions.id = users_permissions.permission_id
+	WHERE permissions.code = $1
+	UNION
+	SELECT users_roles.user_id
+	FROM users_roles
+	INNER JOIN roles_permissions ON roles_permissions.role_id = users_roles.role_id
+	INNER JOIN permissions ON permissions.id = roles_permissions.permission_id
+	WHERE permissions.code = $1`
+
+	return m.getUserIDs(query, code)
+}
+
+// GetUsersWithRole returns the ids of the users holding the role.
+func (m PermissionModel) GetUsersWithRole(id int64) ([]int64, error) {
+	query := `
+	SELECT user_id
+	FROM users_roles
+	WHERE role_id = $1`
+
+	return m.getUserIDs(query, id)
+}
+
+func (m PermissionModel) getUserIDs(query string, args ...interface{}) ([]int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	userIDs := []int64{}
+	for rows.Next() {
+		var userID int64
+		err := rows.Scan(&userID)
+		if err != nil {
+			return nil, err
+		}
+		userIDs = append(userIDs, userID)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return userIDs, nil
+}
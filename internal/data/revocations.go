@@ -0,0 +1,133 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Revocations lists the signed access tokens that were revoked before they
+// expired, either one at a time by their jti or all of a user's tokens issued
+// before a point in time.
+type Revocations struct {
+	Tokens map[string]time.Time
+	Users  map[int64]time.Time
+}
+
+type RevocationModel struct {
+	DB *sql.DB
+}
+
+// RevokeToken revokes the token with the given jti until it expires.
+func (m RevocationModel) RevokeToken(jti string, expiry time.Time) error {
+	query := `
+	INSERT INTO jwt_revocations (jti, expiry)
+	VALUES ($1, $2)
+	ON CONFLICT (jti) DO NOTHING`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, jti, expiry)
+	return err
+}
+
+// RevokeUser revokes every token of the user issued before at.
+func (m RevocationModel) RevokeUser(userID int64, at time.Time) error {
+	query := `
+	INSERT INTO jwt_user_revocations (user_id, revoked_at)
+	VALUES ($1, $2)
+	ON CONFLICT (user_id) DO UPDATE
+	SET revoked_at = GREATEST(jwt_user_revocations.revoked_at, EXCLUDED.revoked_at)`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, userID, at)
+	return err
+}
+
+// GetAll returns the revocations that still apply to tokens valid for at most
+// maxAge.
+func (m RevocationModel) GetAll(maxAge time.Duration) (*Revocations, error) {
+	revocations := Revocations{
+		Tokens: make(map[string]time.Time),
+		Users:  make(map[int64]time.Time),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `SELECT jti, expiry FROM jwt_revocations WHERE expiry > NOW()`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var jti string
+		var expiry time.Time
+		err := rows.Scan(&jti, &expiry)
+		if err != nil {
+			return nil, err
+		}
+		revocations.Tokens[jti] = expiry
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	rows, err = m.DB.QueryContext(ctx, `SELECT user_id, revoked_at FROM jwt_user_revocations WHERE revoked_at > $1`, time.Now().Add(-maxAge))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID int64
+		var revokedAt time.Time
+		err := rows.Scan(&userID, &revokedAt)
+		if err != nil {
+			return nil, err
+		}
+		revocations.Users[userID] = revokedAt
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &revocations, nil
+}
+
+// DeleteExpired removes the revocations of tokens that have expired anyway,
+// returning how many were removed.
+func (m RevocationModel) DeleteExpired(maxAge time.Duration) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `DELETE FROM jwt_revocations WHERE expiry <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	tokens, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	result, err = tx.ExecContext(ctx, `DELETE FROM jwt_user_revocations WHERE revoked_at <= $1`, time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, err
+	}
+	users, err := result.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return tokens + users, tx.Commit()
+}
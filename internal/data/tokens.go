@@ -6,10 +6,12 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"encoding/base32"
+	"errors"
 	"net/http"
 	"time"
 
 	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/lib/pq"
 	"github.com/tomasen/realip"
 )
 
@@ -17,15 +19,52 @@ const (
 	ScopeActivation     = "activation"
 	ScopeAuthentication = "authentication"
 	ScopePasswordReset  = "password-reset"
+	ScopeRefresh        = "refresh"
+	ScopeMFA            = "mfa"
+	ScopeUnlock         = "unlock"
+	ScopeMagicLogin     = "magic-login"
+	ScopeEmailChange    = "email-change"
+	ScopeEmailRevert    = "email-revert"
+	ScopeOAuthCode      = "oauth-code"
+	ScopeOAuthAccess    = "oauth-access"
+	ScopeOAuthRefresh   = "oauth-refresh"
+	ScopeExport         = "export"
+)
+
+var (
+	ErrTokenReused = errors.New("token reused")
 )
 
 type Token struct {
-	Plaintext string    `json:"token"`
-	Hash      []byte    `json:"-"`
-	UserID    int64     `json:"-"`
-	UserIP    string    `json:"-"`
-	Expiry    time.Time `json:"expiry"`
-	Scope     string    `json:"-"`
+	Plaintext  string    `json:"token"`
+	Hash       []byte    `json:"-"`
+	UserID     int64     `json:"-"`
+	UserIP     string    `json:"-"`
+	UserAgent  string    `json:"-"`
+	DeviceName string    `json:"-"`
+	Expiry     time.Time `json:"expiry"`
+	Scope      string    `json:"-"`
+	Family     []byte    `json:"-"`
+	// ClientID and Permissions are only set on the tokens issued to OAuth
+	// clients, which may only use the permissions they were granted.
+	ClientID    int64       `json:"-"`
+	Permissions Permissions `json:"-"`
+	// Email is only set on email revert tokens, and is the address they
+	// restore.
+	Email string `json:"-"`
+}
+
+// Session describes a live authentication token, one per signed in device.
+type Session struct {
+	ID         int64     `json:"id"`
+	Hash       []byte    `json:"-"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+	Expiry     time.Time `json:"expiry"`
+	UserIP     string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	DeviceName string    `json:"device_name,omitempty"`
+	Current    bool      `json:"current"`
 }
 
 func generateToken(userID int64, ttl time.Duration, scope string, r *http.Request) (*Token, error) {
@@ -47,6 +86,7 @@ func generateToken(userID int64, ttl time.Duration, scope string, r *http.Reques
 	token.Hash = hash[:]
 
 	token.UserIP = realip.FromRequest(r)
+	token.UserAgent = r.UserAgent()
 
 	return token, nil
 }
@@ -56,8 +96,13 @@ func ValidateTokenPlaintext(v *validator.Validator, tokenPlaintext string) {
 	v.Check(len(tokenPlaintext) == 26, "token", "must be 26 bytes long")
 }
 
+func ValidateDeviceName(v *validator.Validator, deviceName string) {
+	v.Check(len(deviceName) <= 100, "device_name", "must not be more than 100 bytes long")
+}
+
 type TokenModel struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Cache *AuthCache
 }
 
 func (m TokenModel) New(userID int64, ttl time.Duration, scope string, r *http.Request) (*Token, error) {
@@ -69,17 +114,214 @@ func (m TokenModel) New(userID int64, ttl time.Duration, scope string, r *http.R
 	return token, err
 }
 
+// NewEmailRevert issues an email revert token that restores email, the address
+// of the user when they asked to change it.
+func (m TokenModel) NewEmailRevert(userID int64, email string, ttl time.Duration, r *http.Request) (*Token, error) {
+	token, err := generateToken(userID, ttl, ScopeEmailRevert, r)
+	if err != nil {
+		return nil, err
+	}
+	token.Email = email
+	err = m.Insert(token)
+	return token, err
+}
+
 func (m TokenModel) Insert(token *Token) error {
 	query := `
-	INSERT INTO tokens (hash, user_id, user_ip, expiry, scope)
-	VALUES ($1, $2, $3, $4, $5)`
-	args := []interface{}{token.Hash, token.UserID, token.UserIP, token.Expiry, token.Scope}
+	INSERT INTO tokens (hash, user_id, user_ip, user_agent, expiry, scope, email)
+	VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''))`
+	args := []interface{}{token.Hash, token.UserID, token.UserIP, token.UserAgent, token.Expiry, token.Scope, token.Email}
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	_, err := m.DB.ExecContext(ctx, query, args...)
 	return err
 }
 
+// NewPair issues an authentication token together with a refresh token that
+// starts a new token family, i.e. a new session on deviceName.
+func (m TokenModel) NewPair(userID int64, deviceName string, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	access, refresh, err := insertPair(ctx, tx, userID, nil, deviceName, accessTTL, refreshTTL, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, err
+	}
+	return access, refresh, nil
+}
+
+// Rotate exchanges an unused refresh token for a new authentication and
+// refresh token in the same family, marking the presented one as used. A
+// refresh token can only be used once: presenting a used one means it has
+// leaked, so the whole family, including its authentication tokens, is
+// revoked and ErrTokenReused returned. The authentication tokens previously
+// issued to the family are revoked so that a session holds a single one.
+// Either way the revoked authentication tokens are returned, so that the
+// signed tokens issued for them can be revoked too.
+func (m TokenModel) Rotate(refreshPlaintext string, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, []*Token, error) {
+	tokenHash := sha256.Sum256([]byte(refreshPlaintext))
+
+	query := `
+	SELECT user_id, family, device_name, used_at, expiry
+	FROM tokens
+	WHERE hash = $1 AND scope = $2
+	FOR UPDATE`
+
+	var (
+		userID     int64
+		family     []byte
+		deviceName string
+		usedAt     sql.NullTime
+		expiry     time.Time
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, query, tokenHash[:], ScopeRefresh).Scan(&userID, &family, &deviceName, &usedAt, &expiry)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, nil, nil, ErrRecordNotFound
+		default:
+			return nil, nil, nil, err
+		}
+	}
+
+	if usedAt.Valid {
+		revoked, err := deleteAccessTokens(ctx, tx, family)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		_, err = tx.ExecContext(ctx, "DELETE FROM tokens WHERE family = $1", family)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		m.Cache.invalidateTokens(userID)
+		return nil, nil, revoked, ErrTokenReused
+	}
+
+	if !expiry.After(time.Now()) {
+		return nil, nil, nil, ErrRecordNotFound
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE tokens SET used_at = now() WHERE hash = $1", tokenHash[:])
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	revoked, err := deleteAccessTokens(ctx, tx, family)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	access, refresh, err := insertPair(ctx, tx, userID, family, deviceName, accessTTL, refreshTTL, r)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	m.Cache.invalidateTokens(userID)
+	return access, refresh, revoked, nil
+}
+
+// deleteAccessTokens deletes the authentication tokens of family, returning
+// their hashes and expiries.
+func deleteAccessTokens(ctx context.Context, tx *sql.Tx, family []byte) ([]*Token, error) {
+	query := `
+	DELETE FROM tokens
+	WHERE family = $1 AND scope = $2
+	RETURNING hash, user_id, expiry`
+
+	rows, err := tx.QueryContext(ctx, query, family, ScopeAuthentication)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*Token{}
+
+	for rows.Next() {
+		token := Token{Scope: ScopeAuthentication, Family: family}
+
+		err := rows.Scan(&token.Hash, &token.UserID, &token.Expiry)
+		if err != nil {
+			return nil, err
+		}
+
+		tokens = append(tokens, &token)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return tokens, nil
+}
+
+// insertPair generates and stores an authentication and a refresh token in
+// family. A nil family starts a new one, identified by the refresh token hash.
+func insertPair(ctx context.Context, tx *sql.Tx, userID int64, family []byte, deviceName string, accessTTL, refreshTTL time.Duration, r *http.Request) (*Token, *Token, error) {
+	access, err := generateToken(userID, accessTTL, ScopeAuthentication, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	refresh, err := generateToken(userID, refreshTTL, ScopeRefresh, r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if family == nil {
+		family = refresh.Hash
+	}
+
+	query := `
+	INSERT INTO tokens (hash, user_id, user_ip, user_agent, device_name, expiry, scope, family)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	for _, token := range []*Token{access, refresh} {
+		token.Family = family
+		token.DeviceName = deviceName
+		args := []interface{}{token.Hash, token.UserID, token.UserIP, token.UserAgent, token.DeviceName, token.Expiry, token.Scope, token.Family}
+
+		_, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return access, refresh, nil
+}
+
 func (m TokenModel) DeleteAllForUser(scope string, userID int64, userIP *string) error {
 	query := "DELETE FROM tokens WHERE scope = $1 AND user_id = $2"
 	args := []interface{}{scope, userID}
@@ -97,5 +339,126 @@ func (m TokenModel) DeleteAllForUser(scope string, userID int64, userIP *string)
 	if err != nil {
 		return err
 	}
+
+	m.Cache.invalidateTokens(userID)
+	return nil
+}
+
+func (m TokenModel) GetSessionsForUser(userID int64) ([]*Session, error) {
+	query := `
+	SELECT id, hash, created_at, COALESCE(last_used_at, created_at), expiry, user_ip, user_agent, device_name
+	FROM tokens
+	WHERE user_id = $1 AND scope = $2 AND expiry > $3
+	ORDER BY COALESCE(last_used_at, created_at) DESC, id DESC`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, ScopeAuthentication, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+
+	for rows.Next() {
+		var session Session
+
+		err := rows.Scan(
+			&session.ID,
+			&session.Hash,
+			&session.CreatedAt,
+			&session.LastUsedAt,
+			&session.Expiry,
+			&session.UserIP,
+			&session.UserAgent,
+			&session.DeviceName,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, &session)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// DeleteSession revokes the session of the user with the given id, along with
+// the refresh tokens of its family.
+func (m TokenModel) DeleteSession(userID, id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	query := `
+	WITH session AS (
+		SELECT id, family FROM tokens WHERE id = $1 AND user_id = $2 AND scope = $3
+	)
+	DELETE FROM tokens
+	WHERE id IN (SELECT id FROM session) OR family IN (SELECT family FROM session)`
+
+	return m.deleteSession(userID, query, id, userID, ScopeAuthentication)
+}
+
+// DeleteSessionForToken revokes the session the authentication token with the
+// given hash belongs to, along with the refresh tokens of its family.
+func (m TokenModel) DeleteSessionForToken(userID int64, tokenHash []byte) error {
+	query := `
+	WITH session AS (
+		SELECT id, family FROM tokens WHERE hash = $1 AND user_id = $2 AND scope = $3
+	)
+	DELETE FROM tokens
+	WHERE id IN (SELECT id FROM session) OR family IN (SELECT family FROM session)`
+
+	return m.deleteSession(userID, query, tokenHash, userID, ScopeAuthentication)
+}
+
+func (m TokenModel) deleteSession(userID int64, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidateTokens(userID)
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteOtherSessions revokes every session of the user except the one the
+// authentication token with the given hash belongs to.
+func (m TokenModel) DeleteOtherSessions(userID int64, tokenHash []byte) error {
+	query := `
+	DELETE FROM tokens
+	WHERE user_id = $1 AND scope = ANY($2) AND hash <> $3
+	AND (family IS NULL OR family <> COALESCE((SELECT family FROM tokens WHERE hash = $3), ''::bytea))`
+
+	args := []interface{}{userID, pq.Array([]string{ScopeAuthentication, ScopeRefresh}), tokenHash}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := m.DB.ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidateTokens(userID)
 	return nil
 }
@@ -5,6 +5,7 @@ import (
 	"crypto/sha256"
 	"database/sql"
 	"errors"
+	"fmt"
 	"strings"
 	"time"
 
@@ -18,16 +19,25 @@ type Password struct {
 }
 
 type User struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	Password  Password  `json:"-"`
-	Activated bool      `json:"activated"`
-	Admin     bool      `json:"admin"`
-	Version   int       `json:"-"`
+	ID         int64     `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Name       string    `json:"name"`
+	Email      string    `json:"email"`
+	Password   Password  `json:"-"`
+	Activated  bool      `json:"activated"`
+	Admin      bool      `json:"admin"`
+	Suspended  bool      `json:"suspended"`
+	MFAEnabled bool      `json:"mfa_enabled"`
+	// PendingEmail is an address the user has asked to change to but not yet
+	// confirmed.
+	PendingEmail string `json:"pending_email,omitempty"`
+	Version      int    `json:"-"`
 }
 
+// mfaEnabledQuery selects whether the user has confirmed two-factor
+// authentication.
+const mfaEnabledQuery = `EXISTS (SELECT 1 FROM users_totp WHERE users_totp.user_id = users.id AND users_totp.confirmed)`
+
 var AnonymousUser = &User{}
 
 func (u *User) IsAnonymous() bool {
@@ -92,7 +102,8 @@ var (
 )
 
 type UserModel struct {
-	DB *sql.DB
+	DB    *sql.DB
+	Cache *AuthCache
 }
 
 func (m UserModel) Insert(user *User) error {
@@ -116,9 +127,45 @@ func (m UserModel) Insert(user *User) error {
 	return nil
 }
 
+func (m UserModel) Get(id int64) (*User, error) {
+	if id < 1 {
+		return nil, ErrRecordNotFound
+	}
+
+	query := `
+	SELECT id, created_at, name, email, password_hash, activated, admin, suspended, ` + mfaEnabledQuery + `, COALESCE(pending_email, ''), version
+	FROM users
+	WHERE id = $1`
+	var user User
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	err := m.DB.QueryRowContext(ctx, query, id).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Admin,
+		&user.Suspended,
+		&user.MFAEnabled,
+		&user.PendingEmail,
+		&user.Version,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+	return &user, nil
+}
+
 func (m UserModel) GetByEmail(email string) (*User, error) {
 	query := `
-	SELECT id, created_at, name, email, password_hash, activated, admin, version
+	SELECT id, created_at, name, email, password_hash, activated, admin, suspended, ` + mfaEnabledQuery + `, COALESCE(pending_email, ''), version
 	FROM users
 	WHERE email = $1`
 	var user User
@@ -132,6 +179,9 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 		&user.Password.Hash,
 		&user.Activated,
 		&user.Admin,
+		&user.Suspended,
+		&user.MFAEnabled,
+		&user.PendingEmail,
 		&user.Version,
 	)
 	if err != nil {
@@ -148,8 +198,9 @@ func (m UserModel) GetByEmail(email string) (*User, error) {
 func (m UserModel) Update(user *User) error {
 	query := `
 	UPDATE users
-	SET name = $1, email = $2, password_hash = $3, activated = $4, admin = $5, version = version + 1
-	WHERE id = $6 AND version = $7
+	SET name = $1, email = $2, password_hash = $3, activated = $4, admin = $5, suspended = $6,
+	pending_email = NULLIF($7, ''), version = version + 1
+	WHERE id = $8 AND version = $9
 	RETURNING version`
 	args := []interface{}{
 		user.Name,
@@ -157,6 +208,8 @@ func (m UserModel) Update(user *User) error {
 		user.Password.Hash,
 		user.Activated,
 		user.Admin,
+		user.Suspended,
+		user.PendingEmail,
 		user.ID,
 		user.Version,
 	}
@@ -173,24 +226,182 @@ func (m UserModel) Update(user *User) error {
 			return err
 		}
 	}
+
+	m.Cache.invalidateTokens(user.ID)
 	return nil
 }
 
+// UserFilter narrows a listing of users down to those whose flags match the
+// non-nil fields.
+type UserFilter struct {
+	Activated *bool
+	Admin     *bool
+	Suspended *bool
+}
+
+// likeEscaper escapes the wildcards of a LIKE pattern.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// GetAll returns the users whose name or email address contains search and
+// that match filter.
+func (m UserModel) GetAll(search string, filter UserFilter, filters Filters) ([]*User, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, created_at, name, email, activated, admin, suspended, `+mfaEnabledQuery+`, COALESCE(pending_email, ''), version
+	FROM users
+	WHERE (name ILIKE $1 OR email ILIKE $1)
+	AND ($2::boolean IS NULL OR activated = $2)
+	AND ($3::boolean IS NULL OR admin = $3)
+	AND ($4::boolean IS NULL OR suspended = $4)
+	ORDER BY %s %s, id ASC
+	LIMIT $5 OFFSET $6`, filters.sortColumn(), filters.sortDirection())
+
+	args := []interface{}{
+		"%" + likeEscaper.Replace(search) + "%",
+		filter.Activated,
+		filter.Admin,
+		filter.Suspended,
+		filters.limit(),
+		filters.offset(),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	users := []*User{}
+
+	for rows.Next() {
+		var user User
+
+		err := rows.Scan(
+			&totalRecords,
+			&user.ID,
+			&user.CreatedAt,
+			&user.Name,
+			&user.Email,
+			&user.Activated,
+			&user.Admin,
+			&user.Suspended,
+			&user.MFAEnabled,
+			&user.PendingEmail,
+			&user.Version,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		users = append(users, &user)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return users, metadata, nil
+}
+
 func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error) {
 	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
 
+	// Only authentication tokens are cached, the other scopes are single use.
+	var gen uint64
+	if tokenScope == ScopeAuthentication {
+		user, g, ok := m.Cache.getUser(tokenHash[:])
+		if ok {
+			return user, nil
+		}
+		gen = g
+	}
+
+	// Looking an authentication token up also records when it was last used,
+	// at most once a minute so that busy sessions do not write on every
+	// request.
+	query := `
+	WITH token AS (
+		SELECT user_id, expiry
+		FROM tokens
+		WHERE hash = $1
+		AND scope = $2
+		AND expiry > $3
+	), touched AS (
+		UPDATE tokens
+		SET last_used_at = now()
+		WHERE hash = $1
+		AND scope = $2
+		AND $2 = $4
+		AND expiry > $3
+		AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')
+	)
+	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.admin, users.suspended, ` + mfaEnabledQuery + `, COALESCE(users.pending_email, ''), users.version, token.expiry
+	FROM users
+	INNER JOIN token
+	ON users.id = token.user_id`
+
+	args := []interface{}{tokenHash[:], tokenScope, time.Now(), ScopeAuthentication}
+
+	var user User
+	var expiry time.Time
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, args...).Scan(
+		&user.ID,
+		&user.CreatedAt,
+		&user.Name,
+		&user.Email,
+		&user.Password.Hash,
+		&user.Activated,
+		&user.Admin,
+		&user.Suspended,
+		&user.MFAEnabled,
+		&user.PendingEmail,
+		&user.Version,
+		&expiry,
+	)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	if tokenScope == ScopeAuthentication {
+		m.Cache.setUser(tokenHash[:], &user, expiry, gen)
+	}
+
+	return &user, nil
+}
+
+// GetForEmailRevertToken returns the user an unexpired email revert token
+// belongs to, together with the address the token restores.
+func (m UserModel) GetForEmailRevertToken(tokenPlaintext string) (*User, string, error) {
+	tokenHash := sha256.Sum256([]byte(tokenPlaintext))
+
 	query := `
-	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.admin, users.version
+	SELECT users.id, users.created_at, users.name, users.email, users.password_hash, users.activated, users.admin, users.suspended, ` + mfaEnabledQuery + `, COALESCE(users.pending_email, ''), users.version, tokens.email
 	FROM users
 	INNER JOIN tokens
 	ON users.id = tokens.user_id
 	WHERE tokens.hash = $1
 	AND tokens.scope = $2
-	AND tokens.expiry > $3`
+	AND tokens.expiry > $3
+	AND tokens.email IS NOT NULL`
 
-	args := []interface{}{tokenHash[:], tokenScope, time.Now()}
+	args := []interface{}{tokenHash[:], ScopeEmailRevert, time.Now()}
 
 	var user User
+	var email string
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -203,18 +414,22 @@ func (m UserModel) GetForToken(tokenScope, tokenPlaintext string) (*User, error)
 		&user.Password.Hash,
 		&user.Activated,
 		&user.Admin,
+		&user.Suspended,
+		&user.MFAEnabled,
+		&user.PendingEmail,
 		&user.Version,
+		&email,
 	)
 	if err != nil {
 		switch {
 		case errors.Is(err, sql.ErrNoRows):
-			return nil, ErrRecordNotFound
+			return nil, "", ErrRecordNotFound
 		default:
-			return nil, err
+			return nil, "", err
 		}
 	}
 
-	return &user, nil
+	return &user, email, nil
 }
 
 func (m UserModel) ChangePassword(id int64, newPassword string) error {
@@ -224,7 +439,12 @@ func (m UserModel) ChangePassword(id int64, newPassword string) error {
 	}
 	query := "UPDATE users SET password_hash = $1 WHERE id = $2"
 	_, err = m.DB.Exec(query, newHashedPassword, id)
-	return err
+	if err != nil {
+		return err
+	}
+
+	m.Cache.invalidateTokens(id)
+	return nil
 
 }
 
@@ -245,6 +465,9 @@ func (m UserModel) Delete(id int64) error {
 		return err
 	}
 
+	m.Cache.invalidateTokens(id)
+	m.Cache.invalidatePermissions(id)
+
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
 		return err
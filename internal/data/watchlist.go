@@ -0,0 +1,317 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/IfedayoAwe/greenlight/internal/validator"
+	"github.com/lib/pq"
+)
+
+var ErrDuplicateWatchlistEntry = errors.New("duplicate watchlist entry")
+
+// WatchlistEntry is a movie a user wants to watch.
+type WatchlistEntry struct {
+	AddedAt time.Time `json:"added_at"`
+	Movie   *Movie    `json:"movie"`
+}
+
+// WatchedEntry records that a user watched a movie on a given day. A movie can
+// be logged more than once, e.g. when it is rewatched.
+type WatchedEntry struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"-"`
+	MovieID   int64  `json:"movie_id"`
+	WatchedOn Date   `json:"watched_on"`
+	Rating    *int32 `json:"rating,omitempty"`
+	Movie     *Movie `json:"movie,omitempty"`
+}
+
+func ValidateWatchedEntry(v *validator.Validator, entry *WatchedEntry) {
+	v.Check(entry.MovieID > 0, "movie_id", "must be provided")
+	v.Check(!time.Time(entry.WatchedOn).After(time.Now()), "watched_on", "must not be in the future")
+	v.Check(time.Time(entry.WatchedOn).Year() >= 1888, "watched_on", "must not be before 1888")
+	if entry.Rating != nil {
+		v.Check(*entry.Rating >= 1 && *entry.Rating <= 10, "rating", "must be between 1 and 10")
+	}
+}
+
+type WatchlistModel struct {
+	DB *sql.DB
+}
+
+func (m WatchlistModel) Add(userID, movieID int64) (*WatchlistEntry, error) {
+	query := `
+	INSERT INTO watchlist (user_id, movie_id)
+	VALUES ($1, $2)
+	RETURNING added_at`
+
+	entry := WatchlistEntry{}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	err := m.DB.QueryRowContext(ctx, query, userID, movieID).Scan(&entry.AddedAt)
+	if err != nil {
+		switch {
+		case err.Error() == `pq: duplicate key value violates unique constraint "watchlist_pkey"`:
+			return nil, ErrDuplicateWatchlistEntry
+		default:
+			return nil, err
+		}
+	}
+
+	return &entry, nil
+}
+
+func (m WatchlistModel) Remove(userID, movieID int64) error {
+	query := `
+	DELETE FROM watchlist
+	WHERE user_id = $1 AND movie_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, userID, movieID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll returns the watchlist of the user, leaving out movies in the trash.
+// Besides added_at, the listing can be sorted on the columns of the movies.
+func (m WatchlistModel) GetAll(userID int64, filters Filters) ([]*WatchlistEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), added_at, id, title, year, runtime, genres, version, rating, rating_count
+	FROM (
+		SELECT watchlist.added_at, movies.id, movies.title, movies.year, movies.runtime, movies.genres, movies.version,
+			ratings.rating, ratings.rating_count
+		FROM watchlist
+		INNER JOIN movies ON movies.id = watchlist.movie_id
+		LEFT JOIN LATERAL (`+ratingsQuery+`) ratings ON true
+		WHERE watchlist.user_id = $1
+		AND movies.deleted_at IS NULL
+	) entries
+	ORDER BY %s %s, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*WatchlistEntry{}
+
+	for rows.Next() {
+		entry := WatchlistEntry{Movie: &Movie{}}
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.AddedAt,
+			&entry.Movie.ID,
+			&entry.Movie.Title,
+			&entry.Movie.Year,
+			&entry.Movie.Runtime,
+			pq.Array(&entry.Movie.Genres),
+			&entry.Movie.Version,
+			&entry.Movie.AverageRating,
+			&entry.Movie.RatingCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
+
+// SetFlags fills in whether each of the movies is on the watchlist of the
+// user and whether the user has watched it.
+func (m WatchlistModel) SetFlags(userID int64, movies ...*Movie) error {
+	if len(movies) == 0 {
+		return nil
+	}
+
+	query := `
+	SELECT ids.id,
+		EXISTS (SELECT 1 FROM watchlist WHERE watchlist.user_id = $1 AND watchlist.movie_id = ids.id),
+		EXISTS (SELECT 1 FROM watched WHERE watched.user_id = $1 AND watched.movie_id = ids.id)
+	FROM unnest($2::bigint[]) AS ids(id)`
+
+	ids := make([]int64, len(movies))
+	for i, movie := range movies {
+		ids[i] = movie.ID
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	type flags struct{ inWatchlist, watched bool }
+	flagsByID := make(map[int64]flags, len(movies))
+
+	for rows.Next() {
+		var id int64
+		var f flags
+
+		err := rows.Scan(&id, &f.inWatchlist, &f.watched)
+		if err != nil {
+			return err
+		}
+
+		flagsByID[id] = f
+	}
+
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, movie := range movies {
+		f := flagsByID[movie.ID]
+		movie.InWatchlist = &f.inWatchlist
+		movie.Watched = &f.watched
+	}
+
+	return nil
+}
+
+func (m WatchlistModel) InsertWatched(entry *WatchedEntry) error {
+	query := `
+	INSERT INTO watched (user_id, movie_id, watched_on, rating)
+	VALUES ($1, $2, $3, $4)
+	RETURNING id`
+
+	args := []interface{}{entry.UserID, entry.MovieID, time.Time(entry.WatchedOn), entry.Rating}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&entry.ID)
+}
+
+func (m WatchlistModel) DeleteWatched(userID, id int64) error {
+	query := `
+	DELETE FROM watched
+	WHERE id = $1 AND user_id = $2`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAllWatched returns the watched log of the user, leaving out movies in the
+// trash. Besides id, watched_on and rating, the listing can be sorted on the
+// columns of the movies, with average_rating being the rating of the movie.
+func (m WatchlistModel) GetAllWatched(userID int64, filters Filters) ([]*WatchedEntry, Metadata, error) {
+	query := fmt.Sprintf(`
+	SELECT count(*) OVER(), id, movie_id, watched_on, rating, title, year, runtime, genres, version, average_rating, rating_count
+	FROM (
+		SELECT watched.id, watched.movie_id, watched.watched_on, watched.rating,
+			movies.title, movies.year, movies.runtime, movies.genres, movies.version,
+			ratings.rating AS average_rating, ratings.rating_count
+		FROM watched
+		INNER JOIN movies ON movies.id = watched.movie_id
+		LEFT JOIN LATERAL (`+ratingsQuery+`) ratings ON true
+		WHERE watched.user_id = $1
+		AND movies.deleted_at IS NULL
+	) entries
+	ORDER BY %s %s NULLS LAST, id ASC
+	LIMIT $2 OFFSET $3`, filters.sortColumn(), filters.sortDirection())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, userID, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	entries := []*WatchedEntry{}
+
+	for rows.Next() {
+		entry := WatchedEntry{UserID: userID, Movie: &Movie{}}
+		var watchedOn time.Time
+		var rating sql.NullInt32
+
+		err := rows.Scan(
+			&totalRecords,
+			&entry.ID,
+			&entry.MovieID,
+			&watchedOn,
+			&rating,
+			&entry.Movie.Title,
+			&entry.Movie.Year,
+			&entry.Movie.Runtime,
+			pq.Array(&entry.Movie.Genres),
+			&entry.Movie.Version,
+			&entry.Movie.AverageRating,
+			&entry.Movie.RatingCount,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+
+		entry.WatchedOn = Date(watchedOn)
+		if rating.Valid {
+			entry.Rating = &rating.Int32
+		}
+		entry.Movie.ID = entry.MovieID
+
+		entries = append(entries, &entry)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return entries, metadata, nil
+}
@@ -0,0 +1,279 @@
+// Package jwt signs and verifies compact JSON Web Tokens (RFC 7519) with HS256
+// or EdDSA (Ed25519) keys. A KeySet signs with its first key and verifies with
+// any of its keys, picked by the kid header, so that keys can be rotated
+// without invalidating the tokens already issued.
+package jwt
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+)
+
+const (
+	HS256 = "HS256"
+	EdDSA = "EdDSA"
+)
+
+var (
+	ErrMalformed        = errors.New("jwt: malformed token")
+	ErrUnknownKey       = errors.New("jwt: unknown signing key")
+	ErrInvalidSignature = errors.New("jwt: invalid signature")
+	ErrExpired          = errors.New("jwt: token has expired")
+	ErrInvalidIssuer    = errors.New("jwt: invalid issuer")
+)
+
+// encoding is strict so that every token has a single encoding: the lax
+// decoder ignores the unused bits of the last character, which would let a
+// token be altered without changing what its signature decodes to.
+var encoding = base64.RawURLEncoding.Strict()
+
+// Key is a named signing key.
+type Key struct {
+	ID        string
+	Algorithm string
+	secret    []byte
+	private   ed25519.PrivateKey
+	public    ed25519.PublicKey
+}
+
+// NewHS256Key returns a symmetric key. The secret must be at least 32 bytes,
+// the size of the HMAC-SHA256 output.
+func NewHS256Key(id string, secret []byte) (*Key, error) {
+	if len(secret) < 32 {
+		return nil, fmt.Errorf("jwt: HS256 key %q must be at least 32 bytes long", id)
+	}
+	return &Key{ID: id, Algorithm: HS256, secret: secret}, nil
+}
+
+// NewEdDSAKey returns an Ed25519 key derived from a 32 byte seed.
+func NewEdDSAKey(id string, seed []byte) (*Key, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("jwt: EdDSA key %q must be a %d byte seed", id, ed25519.SeedSize)
+	}
+	private := ed25519.NewKeyFromSeed(seed)
+	return &Key{ID: id, Algorithm: EdDSA, private: private, public: private.Public().(ed25519.PublicKey)}, nil
+}
+
+func (k *Key) sign(input []byte) []byte {
+	switch k.Algorithm {
+	case HS256:
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	default:
+		return ed25519.Sign(k.private, input)
+	}
+}
+
+func (k *Key) verify(input, signature []byte) bool {
+	switch k.Algorithm {
+	case HS256:
+		return hmac.Equal(k.sign(input), signature)
+	default:
+		return ed25519.Verify(k.public, input, signature)
+	}
+}
+
+// KeySet holds the keys tokens are signed and verified with.
+type KeySet struct {
+	keys []*Key
+}
+
+// NewKeySet returns a KeySet that signs with the first key.
+func NewKeySet(keys ...*Key) (*KeySet, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("jwt: at least one key is required")
+	}
+	seen := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		if key.ID == "" || seen[key.ID] {
+			return nil, fmt.Errorf("jwt: key ids must be unique and not empty, got %q", key.ID)
+		}
+		seen[key.ID] = true
+	}
+	return &KeySet{keys: keys}, nil
+}
+
+// ParseKeySet parses a comma separated list of kid:alg:key entries, where key
+// is the base64url encoded HS256 secret or Ed25519 seed, for example
+// "2024-06:EdDSA:<seed>,2024-01:HS256:<secret>".
+func ParseKeySet(s string) (*KeySet, error) {
+	var keys []*Key
+	for _, entry := range strings.Split(s, ",") {
+		parts := strings.Split(strings.TrimSpace(entry), ":")
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("jwt: key %q must be of the form kid:alg:key", entry)
+		}
+
+		material, err := encoding.DecodeString(strings.TrimRight(parts[2], "="))
+		if err != nil {
+			return nil, fmt.Errorf("jwt: key %q is not base64url encoded", parts[0])
+		}
+
+		var key *Key
+		switch parts[1] {
+		case HS256:
+			key, err = NewHS256Key(parts[0], material)
+		case EdDSA:
+			key, err = NewEdDSAKey(parts[0], material)
+		default:
+			err = fmt.Errorf("jwt: key %q has unsupported algorithm %q", parts[0], parts[1])
+		}
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return NewKeySet(keys...)
+}
+
+type header struct {
+	Algorithm string `json:"alg"`
+	Type      string `json:"typ"`
+	KeyID     string `json:"kid"`
+}
+
+// Sign encodes claims as JSON and signs them with the first key of the set.
+func (ks *KeySet) Sign(claims interface{}) (string, error) {
+	key := ks.keys[0]
+
+	h, err := json.Marshal(header{Algorithm: key.Algorithm, Type: "JWT", KeyID: key.ID})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+
+	input := encoding.EncodeToString(h) + "." + encoding.EncodeToString(payload)
+	return input + "." + encoding.EncodeToString(key.sign([]byte(input))), nil
+}
+
+// Verify checks the signature of token and decodes its claims into dst. It
+// does not check the registered claims; use Claims.Valid for that.
+func (ks *KeySet) Verify(token string, dst interface{}) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return ErrMalformed
+	}
+
+	rawHeader, err := encoding.DecodeString(parts[0])
+	if err != nil {
+		return ErrMalformed
+	}
+	var h header
+	err = json.Unmarshal(rawHeader, &h)
+	if err != nil {
+		return ErrMalformed
+	}
+
+	key := ks.lookup(h.KeyID)
+	// The algorithm is fixed by the key rather than trusted from the header,
+	// so that a token cannot downgrade to another algorithm.
+	if key == nil || key.Algorithm != h.Algorithm {
+		return ErrUnknownKey
+	}
+
+	signature, err := encoding.DecodeString(parts[2])
+	if err != nil {
+		return ErrMalformed
+	}
+	if !key.verify([]byte(parts[0]+"."+parts[1]), signature) {
+		return ErrInvalidSignature
+	}
+
+	payload, err := encoding.DecodeString(parts[1])
+	if err != nil {
+		return ErrMalformed
+	}
+	err = json.Unmarshal(payload, dst)
+	if err != nil {
+		return ErrMalformed
+	}
+	return nil
+}
+
+func (ks *KeySet) lookup(id string) *Key {
+	for _, key := range ks.keys {
+		if key.ID == id {
+			return key
+		}
+	}
+	return nil
+}
+
+// JWK is the public half of a key as published in a JSON Web Key Set
+// (RFC 7517, RFC 8037).
+type JWK struct {
+	KeyType   string `json:"kty"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+}
+
+// PublicKeys returns the EdDSA keys of the set. HS256 secrets are never
+// published, so a set of only HS256 keys returns an empty slice.
+func (ks *KeySet) PublicKeys() []JWK {
+	jwks := []JWK{}
+	if ks == nil {
+		return jwks
+	}
+	for _, key := range ks.keys {
+		if key.Algorithm != EdDSA {
+			continue
+		}
+		jwks = append(jwks, JWK{
+			KeyType:   "OKP",
+			Curve:     "Ed25519",
+			X:         encoding.EncodeToString(key.public),
+			KeyID:     key.ID,
+			Algorithm: EdDSA,
+			Use:       "sig",
+		})
+	}
+	return jwks
+}
+
+// Claims are the registered claims of a token. IssuedAt has millisecond
+// precision, see NumericDate.
+type Claims struct {
+	Issuer    string  `json:"iss"`
+	Subject   string  `json:"sub"`
+	ID        string  `json:"jti"`
+	IssuedAt  float64 `json:"iat"`
+	ExpiresAt int64   `json:"exp"`
+}
+
+// NumericDate returns t in seconds since the epoch with millisecond
+// precision. RFC 7519 allows a NumericDate to be fractional.
+func NumericDate(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// Issued returns the time the token was issued, to the millisecond.
+func (c Claims) Issued() time.Time {
+	return time.UnixMilli(int64(math.Round(c.IssuedAt * 1000)))
+}
+
+// Valid reports whether the claims were issued by issuer and have not
+// expired at now.
+func (c Claims) Valid(issuer string, now time.Time) error {
+	if c.Issuer != issuer {
+		return ErrInvalidIssuer
+	}
+	if now.Unix() >= c.ExpiresAt {
+		return ErrExpired
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package jwt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestKey(t *testing.T, id, alg string, b byte) *Key {
+	var key *Key
+	var err error
+	switch alg {
+	case EdDSA:
+		key, err = NewEdDSAKey(id, bytes.Repeat([]byte{b}, 32))
+	default:
+		key, err = NewHS256Key(id, bytes.Repeat([]byte{b}, 32))
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func newTestKeySet(t *testing.T, keys ...*Key) *KeySet {
+	ks, err := NewKeySet(keys...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ks
+}
+
+func signTestToken(t *testing.T, ks *KeySet, claims Claims) string {
+	token, err := ks.Sign(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// flipBit flips a bit of the decoded part i of token and encodes it again.
+func flipBit(t *testing.T, token string, i int) string {
+	parts := strings.Split(token, ".")
+	b, err := encoding.DecodeString(parts[i])
+	if err != nil {
+		t.Fatal(err)
+	}
+	b[len(b)-1] ^= 1
+	parts[i] = encoding.EncodeToString(b)
+	return strings.Join(parts, ".")
+}
+
+func TestSignAndVerify(t *testing.T) {
+	claims := Claims{Issuer: "greenlight", Subject: "1", ID: "jti", IssuedAt: 1700000000, ExpiresAt: 1700003600}
+
+	for _, alg := range []string{HS256, EdDSA} {
+		t.Run(alg, func(t *testing.T) {
+			ks := newTestKeySet(t, newTestKey(t, "key", alg, 1))
+			token := signTestToken(t, ks, claims)
+
+			if n := strings.Count(token, "."); n != 2 {
+				t.Fatalf("want 3 parts; got %d", n+1)
+			}
+
+			var got Claims
+			err := ks.Verify(token, &got)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != claims {
+				t.Errorf("want %+v; got %+v", claims, got)
+			}
+		})
+	}
+}
+
+func TestVerify(t *testing.T) {
+	current := newTestKey(t, "2024-06", EdDSA, 1)
+	previous := newTestKey(t, "2024-01", HS256, 2)
+	ks := newTestKeySet(t, current, previous)
+
+	claims := Claims{Subject: "1"}
+	token := signTestToken(t, ks, claims)
+	parts := strings.Split(token, ".")
+
+	// A 64 byte Ed25519 signature leaves two unused bits in the last base64
+	// character, so setting one gives a second encoding of the same signature.
+	const alphabet = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+	last := strings.IndexByte(alphabet, token[len(token)-1])
+	nonCanonical := token[:len(token)-1] + string(alphabet[last|1])
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr error
+	}{
+		{"Valid", token, nil},
+		{"RotatedKey", signTestToken(t, newTestKeySet(t, previous), claims), nil},
+		{"TamperedSignature", flipBit(t, token, 2), ErrInvalidSignature},
+		{"TamperedPayload", flipBit(t, token, 1), ErrInvalidSignature},
+		{"UnknownKey", signTestToken(t, newTestKeySet(t, newTestKey(t, "2023-01", HS256, 2)), claims), ErrUnknownKey},
+		{"ForgedWithKnownKid", signTestToken(t, newTestKeySet(t, newTestKey(t, "2024-01", HS256, 3)), claims), ErrInvalidSignature},
+		{"AlgorithmMismatch", signTestToken(t, newTestKeySet(t, newTestKey(t, "2024-06", HS256, 1)), claims), ErrUnknownKey},
+		{"NonCanonicalEncoding", nonCanonical, ErrMalformed},
+		{"TooFewParts", parts[0] + "." + parts[1], ErrMalformed},
+		{"BadHeader", "!." + parts[1] + "." + parts[2], ErrMalformed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Claims
+			err := ks.Verify(tt.token, &got)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestClaimsValid(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name    string
+		claims  Claims
+		wantErr error
+	}{
+		{"Valid", Claims{Issuer: "greenlight", ExpiresAt: now.Unix() + 1}, nil},
+		{"Expired", Claims{Issuer: "greenlight", ExpiresAt: now.Unix()}, ErrExpired},
+		{"WrongIssuer", Claims{Issuer: "other", ExpiresAt: now.Unix() + 1}, ErrInvalidIssuer},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.claims.Valid("greenlight", now)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("want %v; got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseKeySet(t *testing.T) {
+	secret := encoding.EncodeToString(bytes.Repeat([]byte{2}, 32))
+	seed := encoding.EncodeToString(bytes.Repeat([]byte{1}, 32))
+
+	ks, err := ParseKeySet("2024-06:EdDSA:" + seed + ", 2024-01:HS256:" + secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Only the EdDSA key is published, and the set signs with it.
+	jwks := ks.PublicKeys()
+	if len(jwks) != 1 || jwks[0].KeyID != "2024-06" {
+		t.Errorf("want only the 2024-06 key to be published; got %+v", jwks)
+	}
+	if token := signTestToken(t, ks, Claims{}); !strings.HasPrefix(token, encoding.EncodeToString([]byte(`{"alg":"EdDSA","typ":"JWT","kid":"2024-06"}`))) {
+		t.Errorf("want the token to be signed with the first key; got %q", token)
+	}
+
+	tests := []struct {
+		name string
+		keys string
+	}{
+		{"MissingPart", "2024-01:" + secret},
+		{"UnknownAlgorithm", "2024-01:RS256:" + secret},
+		{"ShortSecret", "2024-01:HS256:" + encoding.EncodeToString([]byte("short"))},
+		{"NotBase64", "2024-01:HS256:!!!"},
+		{"DuplicateID", "2024-01:HS256:" + secret + ",2024-01:HS256:" + secret},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseKeySet(tt.keys)
+			if err == nil {
+				t.Error("want an error")
+			}
+		})
+	}
+}
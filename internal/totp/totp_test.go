@@ -0,0 +1,81 @@
+package totp
+
+import (
+	"testing"
+	"time"
+)
+
+// The RFC 6238 appendix B SHA1 test vectors, truncated to the six digits this
+// package uses.
+var rfcSecret = []byte("12345678901234567890")
+
+var rfcVectors = []struct {
+	name string
+	time int64
+	code string
+}{
+	{"1970", 59, "287082"},
+	{"2005a", 1111111109, "081804"},
+	{"2005b", 1111111111, "050471"},
+	{"2009", 1234567890, "005924"},
+	{"2033", 2000000000, "279037"},
+	{"2603", 20000000000, "353130"},
+}
+
+func TestCode(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			code := Code(rfcSecret, Step(time.Unix(tt.time, 0)))
+			if code != tt.code {
+				t.Errorf("want %q; got %q", tt.code, code)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tt := range rfcVectors {
+		t.Run(tt.name, func(t *testing.T) {
+			at := time.Unix(tt.time, 0)
+
+			step, ok := Validate(rfcSecret, tt.code, at)
+			if !ok {
+				t.Fatalf("want %q to be valid", tt.code)
+			}
+			if want := Step(at); step != want {
+				t.Errorf("want step %d; got %d", want, step)
+			}
+		})
+	}
+
+	at := time.Unix(1111111109, 0)
+	current := Step(at)
+
+	tests := []struct {
+		name     string
+		code     string
+		wantOK   bool
+		wantStep int64
+	}{
+		{"PreviousStep", Code(rfcSecret, current-1), true, current - 1},
+		{"NextStep", Code(rfcSecret, current+1), true, current + 1},
+		{"TwoStepsBehind", Code(rfcSecret, current-2), false, 0},
+		{"TwoStepsAhead", Code(rfcSecret, current+2), false, 0},
+		{"WrongCode", "000000", false, 0},
+		{"TooShort", "81804", false, 0},
+		{"TooLong", "07081804", false, 0},
+		{"Empty", "", false, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tt.code, at)
+			if ok != tt.wantOK {
+				t.Errorf("want ok %t; got %t", tt.wantOK, ok)
+			}
+			if step != tt.wantStep {
+				t.Errorf("want step %d; got %d", tt.wantStep, step)
+			}
+		})
+	}
+}
@@ -0,0 +1,3 @@
+ALTER TABLE permissions DROP COLUMN IF EXISTS requires_mfa;
+DROP TABLE IF EXISTS users_recovery_codes;
+DROP TABLE IF EXISTS users_totp;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS pending_email citext;
+ALTER TABLE users ADD COLUMN IF NOT EXISTS previous_email citext;
\ No newline at end of file
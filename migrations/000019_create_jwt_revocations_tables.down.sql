@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS jwt_user_revocations;
+DROP TABLE IF EXISTS jwt_revocations;
\ No newline at end of file
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS jwt_revocations (
+    jti text PRIMARY KEY,
+    expiry timestamp(0) with time zone NOT NULL
+);
+
+-- Users are not referenced, so that the tokens of a deleted user stay revoked.
+CREATE TABLE IF NOT EXISTS jwt_user_revocations (
+    user_id bigint PRIMARY KEY,
+    revoked_at timestamp with time zone NOT NULL
+);
\ No newline at end of file
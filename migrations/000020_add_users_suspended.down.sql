@@ -0,0 +1 @@
+ALTER TABLE users DROP COLUMN IF EXISTS suspended;
\ No newline at end of file
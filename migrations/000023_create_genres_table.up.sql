@@ -0,0 +1,68 @@
+CREATE TABLE IF NOT EXISTS genres (
+    id bigserial PRIMARY KEY,
+    created_at timestamp(0) with time zone NOT NULL DEFAULT NOW(),
+    slug text UNIQUE NOT NULL,
+    name text NOT NULL,
+    aliases text[] NOT NULL DEFAULT '{}',
+    version integer NOT NULL DEFAULT 1
+);
+
+CREATE INDEX IF NOT EXISTS genres_aliases_idx ON genres USING GIN (aliases);
+
+INSERT INTO genres (slug, name, aliases) VALUES
+    ('action', 'Action', '{}'),
+    ('adventure', 'Adventure', '{}'),
+    ('animation', 'Animation', '{animated}'),
+    ('comedy', 'Comedy', '{}'),
+    ('crime', 'Crime', '{}'),
+    ('documentary', 'Documentary', '{}'),
+    ('drama', 'Drama', '{}'),
+    ('family', 'Family', '{}'),
+    ('fantasy', 'Fantasy', '{}'),
+    ('history', 'History', '{historical}'),
+    ('horror', 'Horror', '{}'),
+    ('music', 'Music', '{musical}'),
+    ('mystery', 'Mystery', '{}'),
+    ('romance', 'Romance', '{romantic}'),
+    ('science-fiction', 'Science Fiction', '{sci-fi,scifi,sf}'),
+    ('thriller', 'Thriller', '{}'),
+    ('war', 'War', '{}'),
+    ('western', 'Western', '{}')
+ON CONFLICT DO NOTHING;
+
+-- Genres already used by movies that are not known yet become genres of their
+-- own, for admins to rename or merge.
+INSERT INTO genres (slug, name)
+SELECT existing.slug, min(existing.genre)
+FROM (
+    SELECT genre, trim(BOTH '-' FROM regexp_replace(lower(genre), '[^a-z0-9]+', '-', 'g')) AS slug
+    FROM movies, unnest(movies.genres) AS genre
+) existing
+WHERE existing.slug <> ''
+AND NOT EXISTS (SELECT 1 FROM genres known WHERE known.slug = existing.slug OR existing.slug = ANY(known.aliases))
+GROUP BY existing.slug;
+
+-- Movies store the slugs of their genres from now on. Revisions keep the
+-- genres as they were, they are normalised again when reverted to. Each movie
+-- whose genres change gets a new version and revision, so that ETags taken
+-- before the migration no longer match.
+WITH normalised AS (
+    SELECT movies.id, ARRAY(
+        SELECT known.slug
+        FROM unnest(movies.genres) WITH ORDINALITY AS g(genre, position)
+        CROSS JOIN LATERAL trim(BOTH '-' FROM regexp_replace(lower(g.genre), '[^a-z0-9]+', '-', 'g')) AS s(slug)
+        INNER JOIN genres known ON known.slug = s.slug OR s.slug = ANY(known.aliases)
+        GROUP BY known.slug
+        ORDER BY min(g.position)
+    ) AS genres
+    FROM movies
+), updated AS (
+    UPDATE movies
+    SET genres = normalised.genres, version = movies.version + 1
+    FROM normalised
+    WHERE normalised.id = movies.id AND normalised.genres <> movies.genres
+    RETURNING movies.id, movies.version, movies.title, movies.year, movies.runtime, movies.genres
+)
+INSERT INTO movie_revisions (movie_id, version, action, title, year, runtime, genres)
+SELECT id, version, 'update', title, year, runtime, genres
+FROM updated;
\ No newline at end of file
@@ -0,0 +1,2 @@
+ALTER TABLE users ADD COLUMN IF NOT EXISTS previous_email citext;
+ALTER TABLE tokens DROP COLUMN IF EXISTS email;
\ No newline at end of file
@@ -0,0 +1,11 @@
+ALTER TABLE tokens ADD COLUMN IF NOT EXISTS email citext;
+
+-- Email revert tokens restore the address they were sent to. Those issued
+-- before it was stored restore the address the user is changing from, or
+-- last changed from once the change is confirmed.
+UPDATE tokens
+SET email = CASE WHEN users.pending_email IS NOT NULL THEN users.email ELSE COALESCE(users.previous_email, users.email) END
+FROM users
+WHERE tokens.user_id = users.id AND tokens.scope = 'email-revert';
+
+ALTER TABLE users DROP COLUMN IF EXISTS previous_email;
\ No newline at end of file